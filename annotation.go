@@ -0,0 +1,79 @@
+package shogi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 指し手に付ける記号。
+type Glyph uint8
+
+const (
+	NoGlyph Glyph = iota
+	GoodMove
+	BrilliantMove
+	Mistake
+	Blunder
+	InterestingMove
+	DubiousMove
+)
+
+var (
+	mapGlyphString = map[Glyph]string{
+		GoodMove:        "!",
+		BrilliantMove:   "!!",
+		Mistake:         "?",
+		Blunder:         "??",
+		InterestingMove: "!?",
+		DubiousMove:     "?!",
+	}
+	mapStringGlyph = map[string]Glyph{
+		"!":  GoodMove,
+		"!!": BrilliantMove,
+		"?":  Mistake,
+		"??": Blunder,
+		"!?": InterestingMove,
+		"?!": DubiousMove,
+	}
+)
+
+func NewGlyph(s string) (Glyph, error) {
+	g, ok := mapStringGlyph[s]
+	if !ok {
+		return NoGlyph, fmt.Errorf("invalid glyph: %s", s)
+	}
+	return g, nil
+}
+
+func (g Glyph) String() string {
+	return mapGlyphString[g]
+}
+
+// エンジンの評価。評価値は先手から見た値。
+type Evaluation struct {
+	// IsMateなら詰みまでの手数で、正なら先手が詰ます。
+	Score  int
+	IsMate bool
+	Depth  int
+	// このノードの局面からの読み筋
+	Pv []Move
+}
+
+func (e *Evaluation) String() string {
+	var s strings.Builder
+	if e.IsMate {
+		s.WriteString(fmt.Sprintf("mate %d", e.Score))
+	} else {
+		s.WriteString(fmt.Sprintf("cp %d", e.Score))
+	}
+	if e.Depth > 0 {
+		s.WriteString(fmt.Sprintf(" depth %d", e.Depth))
+	}
+	if len(e.Pv) > 0 {
+		s.WriteString(" pv")
+		for _, m := range e.Pv {
+			s.WriteString(" " + m.USI())
+		}
+	}
+	return s.String()
+}
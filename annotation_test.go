@@ -0,0 +1,38 @@
+package shogi
+
+import "testing"
+
+func TestGlyph(t *testing.T) {
+	for _, s := range []string{"!", "!!", "?", "??", "!?", "?!"} {
+		g, err := NewGlyph(s)
+		if err != nil {
+			t.Errorf("NewGlyph(%v): %v", s, err)
+		}
+		if g.String() != s {
+			t.Errorf("NewGlyph(%v).String(): got %v", s, g.String())
+		}
+	}
+	if _, err := NewGlyph("!!!"); err == nil {
+		t.Errorf("NewGlyph(!!!): want error")
+	}
+	if NoGlyph.String() != "" {
+		t.Errorf("NoGlyph.String(): want empty, got %v", NoGlyph.String())
+	}
+}
+
+func TestEvaluationString(t *testing.T) {
+	m1, _ := NewMoveFromUSI("7g7f")
+	m2, _ := NewMoveFromUSI("3c3d")
+	tests := []struct {
+		eval *Evaluation
+		want string
+	}{
+		{&Evaluation{Score: 30, Depth: 10, Pv: []Move{m1, m2}}, "cp 30 depth 10 pv 7g7f 3c3d"},
+		{&Evaluation{Score: -5, IsMate: true}, "mate -5"},
+	}
+	for _, test := range tests {
+		if s := test.eval.String(); s != test.want {
+			t.Errorf("want %v, got %v", test.want, s)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package shogi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KIF形式の局面図(BOD)。
+//
+//	後手の持駒：なし
+//	  ９ ８ ７ ６ ５ ４ ３ ２ １
+//	+---------------------------+
+//	|v香v桂v銀v金v玉v金v銀v桂v香|一
+//	...
+//	| 香 桂 銀 金 玉 金 銀 桂 香|九
+//	+---------------------------+
+//	先手の持駒：なし
+//	手数＝0
+//	先手番
+
+var kanjiNumbers = []string{"", "一", "二", "三", "四", "五", "六", "七", "八", "九", "十"}
+
+func kanjiNumber(n int) string {
+	if n <= 10 {
+		return kanjiNumbers[n]
+	}
+	return "十" + kanjiNumbers[n-10]
+}
+
+func parseKanjiNumber(s string) (int, error) {
+	n := 0
+	if strings.HasPrefix(s, "十") {
+		n = 10
+		s = strings.TrimPrefix(s, "十")
+	}
+	if s == "" {
+		if n == 0 {
+			return 0, errors.New("empty number")
+		}
+		return n, nil
+	}
+	for i := 1; i <= 9; i++ {
+		if s == kanjiNumbers[i] {
+			return n + i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid number: %s", s)
+}
+
+func NewPositionFromBOD(bod string) (*Position, error) {
+	var board Board
+	hand := NewHand()
+	// 後手番の行がなければ先手番
+	turn := Black
+	ply := 0
+	rank := 0
+
+	for _, line := range splitLines(bod) {
+		line = strings.TrimRight(line, " 　")
+		switch {
+		case strings.HasPrefix(line, "先手の持駒："), strings.HasPrefix(line, "下手の持駒："):
+			if err := readBODHand(hand, Black, line); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, "後手の持駒："), strings.HasPrefix(line, "上手の持駒："):
+			if err := readBODHand(hand, White, line); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, "|"):
+			if rank > 8 {
+				return nil, errors.New("too many ranks")
+			}
+			if err := readBODRank(&board, rank, line); err != nil {
+				return nil, err
+			}
+			rank++
+		case strings.HasPrefix(line, "手数＝"):
+			// "手数＝5  ▲７六歩  まで"のように最後の指し手が続くことがある
+			fields := strings.Fields(strings.TrimPrefix(line, "手数＝"))
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("invalid ply: %s", line)
+			}
+			n, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid ply: %s", line)
+			}
+			ply = n
+		case line == "先手番", line == "下手番":
+			turn = Black
+		case line == "後手番", line == "上手番":
+			turn = White
+		}
+	}
+	if rank != 9 {
+		return nil, fmt.Errorf("rank should be 9: %d", rank)
+	}
+
+	return &Position{Board: &board, Turn: turn, Hand: hand, Ply: ply}, nil
+}
+
+// "|v香v桂 ・ ..."のように各升目は2文字。
+func readBODRank(b *Board, rank int, line string) error {
+	runes := []rune(strings.TrimPrefix(line, "|"))
+	if len(runes) < 18 {
+		return fmt.Errorf("rank should have 9 squares: %s", line)
+	}
+	for file := 0; file < 9; file++ {
+		prefix, kif := runes[file*2], string(runes[file*2+1])
+		if kif == "・" {
+			continue
+		}
+		pt, err := NewPieceTypeFromKIF(kif)
+		if err != nil {
+			return err
+		}
+		c := Black
+		if prefix == 'v' {
+			c = White
+		}
+		b[rank][file] = NewPiece(pt, c)
+	}
+	return nil
+}
+
+// "先手の持駒：飛　金二　歩三"や"先手の持駒：なし"。
+func readBODHand(h *Hand, c Color, line string) error {
+	pieces := line[strings.Index(line, "：")+len("："):]
+	if pieces == "なし" {
+		return nil
+	}
+	for _, piece := range strings.FieldsFunc(pieces, func(r rune) bool { return r == '　' || r == ' ' }) {
+		runes := []rune(piece)
+		pt, err := NewPieceTypeFromKIF(string(runes[0]))
+		if err != nil {
+			return err
+		}
+		n := 1
+		if len(runes) > 1 {
+			n, err = parseKanjiNumber(string(runes[1:]))
+			if err != nil {
+				return err
+			}
+		}
+		for i := 0; i < n; i++ {
+			if err := h.Add(pt, c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Position) BOD() string {
+	var s strings.Builder
+
+	s.WriteString("後手の持駒：" + p.bodHand(White) + "\n")
+	s.WriteString("  ９ ８ ７ ６ ５ ４ ３ ２ １\n")
+	s.WriteString("+---------------------------+\n")
+	for rank := 0; rank < 9; rank++ {
+		s.WriteString("|")
+		for file := 0; file < 9; file++ {
+			piece := p.Board[rank][file]
+			switch {
+			case piece == NO_PIECE:
+				s.WriteString(" ・")
+			case piece.Color() == White:
+				s.WriteString("v" + bodPieceType(piece.PieceType()))
+			default:
+				s.WriteString(" " + bodPieceType(piece.PieceType()))
+			}
+		}
+		s.WriteString("|" + kanjiNumber(rank+1) + "\n")
+	}
+	s.WriteString("+---------------------------+\n")
+	s.WriteString("先手の持駒：" + p.bodHand(Black) + "\n")
+	s.WriteString(fmt.Sprintf("手数＝%d\n", p.Ply))
+	if p.Turn == White {
+		s.WriteString("後手番\n")
+	} else {
+		s.WriteString("先手番\n")
+	}
+
+	return s.String()
+}
+
+// 局面図では先手も後手も玉を使う。
+func bodPieceType(pt PieceType) string {
+	if pt == OU {
+		return "玉"
+	}
+	return pt.KIF()
+}
+
+func (p *Position) bodHand(c Color) string {
+	var s strings.Builder
+	for pt := HI; pt >= FU; pt-- {
+		n, _ := p.HandGet(pt, c)
+		if n == 0 {
+			continue
+		}
+		s.WriteString(pt.KIF())
+		if n > 1 {
+			s.WriteString(kanjiNumber(n))
+		}
+		s.WriteString("　")
+	}
+	if s.Len() == 0 {
+		return "なし"
+	}
+	return s.String()
+}
@@ -0,0 +1,107 @@
+package shogi
+
+import (
+	"testing"
+)
+
+func TestPositionBOD(t *testing.T) {
+	tests := []struct {
+		sfen string
+		bod  string
+	}{
+		{
+			sfen: "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1",
+			bod: `後手の持駒：なし
+  ９ ８ ７ ６ ５ ４ ３ ２ １
++---------------------------+
+|v香v桂v銀v金v玉v金v銀v桂v香|一
+| ・v飛 ・ ・ ・ ・ ・v角 ・|二
+|v歩v歩v歩v歩v歩v歩v歩v歩v歩|三
+| ・ ・ ・ ・ ・ ・ ・ ・ ・|四
+| ・ ・ ・ ・ ・ ・ ・ ・ ・|五
+| ・ ・ ・ ・ ・ ・ ・ ・ ・|六
+| 歩 歩 歩 歩 歩 歩 歩 歩 歩|七
+| ・ 角 ・ ・ ・ ・ ・ 飛 ・|八
+| 香 桂 銀 金 玉 金 銀 桂 香|九
++---------------------------+
+先手の持駒：なし
+手数＝0
+先手番
+`,
+		},
+		{
+			sfen: "3g2snl/R8/2+P1ppgp1/B1pp4p/G2n1S3/2PbP1P2/KP1+lkPN1P/6S2/L+r3G2L w 3Psn12p 98",
+			bod: `後手の持駒：銀　桂　歩十二　
+  ９ ８ ７ ６ ５ ４ ３ ２ １
++---------------------------+
+| ・ ・ ・v金 ・ ・v銀v桂v香|一
+| 飛 ・ ・ ・ ・ ・ ・ ・ ・|二
+| ・ ・ と ・v歩v歩v金v歩 ・|三
+| 角 ・v歩v歩 ・ ・ ・ ・v歩|四
+| 金 ・ ・v桂 ・ 銀 ・ ・ ・|五
+| ・ ・ 歩v角 歩 ・ 歩 ・ ・|六
+| 玉 歩 ・v杏v玉 歩 桂 ・ 歩|七
+| ・ ・ ・ ・ ・ ・ 銀 ・ ・|八
+| 香v龍 ・ ・ ・ 金 ・ ・ 香|九
++---------------------------+
+先手の持駒：歩三　
+手数＝97
+後手番
+`,
+		},
+	}
+
+	for _, test := range tests {
+		p, err := NewPositionFromSFEN(test.sfen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bod := p.BOD(); bod != test.bod {
+			t.Errorf("%v.BOD():\nwant\n%v\ngot\n%v", test.sfen, test.bod, bod)
+		}
+		p, err = NewPositionFromBOD(test.bod)
+		if err != nil {
+			t.Fatalf("NewPositionFromBOD(%v): %v", test.bod, err)
+		}
+		if sfen := p.SFEN(); sfen != test.sfen {
+			t.Errorf("NewPositionFromBOD(%v): want %v, got %v", test.bod, test.sfen, sfen)
+		}
+	}
+}
+
+func TestNewPositionFromBOD(t *testing.T) {
+	// 詰将棋でよく見る形式
+	bod := `後手の持駒：飛二　角　金三　銀四　桂三　香三　歩十七
+  ９ ８ ７ ６ ５ ４ ３ ２ １
++---------------------------+
+| ・ ・ ・ ・ ・ ・ ・v桂v香|一
+| ・ ・ ・ ・ ・ ・ ・ ・v王|二
+| ・ ・ ・ ・ ・ ・ ・ ・ ・|三
+| ・ ・ ・ ・ ・ ・ ・ ・ ・|四
+| ・ ・ ・ ・ ・ ・ ・ ・ ・|五
+| ・ ・ ・ ・ ・ ・ ・ ・ ・|六
+| ・ ・ ・ ・ ・ ・ ・ ・ ・|七
+| ・ ・ ・ ・ ・ ・ ・ ・ ・|八
+| ・ ・ ・ ・ ・ ・ ・ ・ ・|九
++---------------------------+
+先手の持駒：角　金　歩
+`
+	want := "7nl/8k/9/9/9/9/9/9/9 b BGP2rb3g4s3n3l17p 1"
+	p, err := NewPositionFromBOD(bod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sfen := p.SFEN(); sfen != want {
+		t.Errorf("want %v, got %v", want, sfen)
+	}
+
+	for _, bod := range []string{
+		"",
+		"先手の持駒：歩百\n",
+		"|v香v桂\n",
+	} {
+		if _, err := NewPositionFromBOD(bod); err == nil {
+			t.Errorf("NewPositionFromBOD(%v): want error", bod)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package clock
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/eru1a/shogi-go"
+)
+
+var ErrTimeUp = errors.New("time up")
+
+// 対局時計。持ち時間を使い切ったら1手ごとに秒読みが使え、
+// 指し終えるごとに加算(フィッシャー)が持ち時間に足される。
+// 持ち時間がゼロのTimeControlは時間無制限。
+type Clock struct {
+	mu sync.Mutex
+	ts TimeSource
+
+	tc        map[shogi.Color]shogi.TimeControl
+	remaining map[shogi.Color]time.Duration
+	// 秒読みを使い切ってからも時間切れにしない猶予(通信の遅延など)
+	grace time.Duration
+
+	turn    shogi.Color
+	running bool
+	started time.Time
+	timer   Timer
+	flagged shogi.Color
+	// 時間切れになった手の消費時間
+	flagElapsed time.Duration
+	flagC       chan shogi.Color
+}
+
+// tsがnilならRealTimeを使う。
+func NewClock(black, white shogi.TimeControl, grace time.Duration, ts TimeSource) *Clock {
+	if ts == nil {
+		ts = RealTime
+	}
+	return &Clock{
+		ts: ts,
+		tc: map[shogi.Color]shogi.TimeControl{
+			shogi.Black: black,
+			shogi.White: white,
+		},
+		remaining: map[shogi.Color]time.Duration{
+			shogi.Black: black.Main,
+			shogi.White: white.Main,
+		},
+		grace:   grace,
+		turn:    shogi.NO_COLOR,
+		flagged: shogi.NO_COLOR,
+		flagC:   make(chan shogi.Color, 1),
+	}
+}
+
+// 手番側の時計を動かす。
+func (c *Clock) Start(turn shogi.Color) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.start(turn)
+}
+
+func (c *Clock) start(turn shogi.Color) error {
+	if c.flagged != shogi.NO_COLOR {
+		return ErrTimeUp
+	}
+	if c.running {
+		return errors.New("clock is already running")
+	}
+	c.turn = turn
+	c.running = true
+	c.started = c.ts.Now()
+	if !c.tc[turn].IsZero() {
+		started := c.started
+		c.timer = c.ts.AfterFunc(c.limit(turn), func() { c.onTimer(started) })
+	}
+	return nil
+}
+
+// 手番側の時計を止めてこの手の消費時間を返す。
+// 時間切れならErrTimeUpを返す。
+func (c *Clock) Stop() (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stop()
+}
+
+func (c *Clock) stop() (time.Duration, error) {
+	if !c.running {
+		return 0, errors.New("clock is not running")
+	}
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.running = false
+	elapsed := c.ts.Now().Sub(c.started)
+	if c.consume(c.turn, elapsed) {
+		return elapsed, ErrTimeUp
+	}
+	return elapsed, nil
+}
+
+// 手番側の時計を止めて加算し、相手の時計を動かす。
+// 手番側の消費時間を返す。
+func (c *Clock) Switch() (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elapsed, err := c.stop()
+	if err != nil {
+		return elapsed, err
+	}
+	c.remaining[c.turn] += c.tc[c.turn].Increment
+	return elapsed, c.start(c.turn.Inv())
+}
+
+// 手番側の指し手mで時計を切り替え、消費時間をtreeに記録する。
+// 時間切れならmの代わりに時間切れをtreeに追加してErrTimeUpを返す。
+// 指し手で終局したら時計は止まったままになる。
+func (c *Clock) Move(tree *shogi.GameTree, m shogi.Move) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var elapsed time.Duration
+	var err error
+	if c.flagged != shogi.NO_COLOR {
+		// 既にタイマーで時間切れになっている
+		elapsed, err = c.flagElapsed, ErrTimeUp
+	} else {
+		elapsed, err = c.stop()
+	}
+	if err == ErrTimeUp {
+		if err := tree.Move(shogi.TimeUpMove); err != nil {
+			return err
+		}
+		tree.SetTime(elapsed)
+		return ErrTimeUp
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tree.Move(m); err != nil {
+		return err
+	}
+	node := tree.Current
+	// 詰みなどが自動で追加されたら指し手のノードに記録する
+	if node.MoveData.IsTerminal() && !m.IsTerminal() {
+		node = node.Prev
+	}
+	node.SetTime(elapsed)
+	if tree.Current.MoveData.IsTerminal() {
+		return nil
+	}
+
+	c.remaining[c.turn] += c.tc[c.turn].Increment
+	return c.start(c.turn.Inv())
+}
+
+// 消費時間を持ち時間から引く。時間切れならtrue。
+func (c *Clock) consume(color shogi.Color, elapsed time.Duration) bool {
+	if c.tc[color].IsZero() {
+		return false
+	}
+	limit := c.limit(color)
+	if elapsed >= c.remaining[color] {
+		c.remaining[color] = 0
+	} else {
+		c.remaining[color] -= elapsed
+	}
+	if elapsed >= limit {
+		c.flagged = color
+		c.flagElapsed = elapsed
+		return true
+	}
+	return false
+}
+
+// この手で使える時間。
+func (c *Clock) limit(color shogi.Color) time.Duration {
+	return c.remaining[color] + c.tc[color].Byoyomi + c.grace
+}
+
+func (c *Clock) onTimer(started time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 既に止めた時計のタイマー
+	if !c.running || !c.started.Equal(started) {
+		return
+	}
+	c.running = false
+	c.timer = nil
+	elapsed := c.ts.Now().Sub(c.started)
+	c.consume(c.turn, elapsed)
+	c.flagged = c.turn
+	c.flagElapsed = elapsed
+	select {
+	case c.flagC <- c.turn:
+	default:
+	}
+}
+
+// 時間切れになった側が送られる。
+func (c *Clock) Flag() <-chan shogi.Color {
+	return c.flagC
+}
+
+// 時間切れになった側。
+func (c *Clock) Flagged() (shogi.Color, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.flagged, c.flagged != shogi.NO_COLOR
+}
+
+// 残りの持ち時間。動いている時計は今の手の消費時間を引く。秒読みは含まない。
+func (c *Clock) Remaining(color shogi.Color) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	remaining := c.remaining[color]
+	if c.running && c.turn == color {
+		remaining -= c.ts.Now().Sub(c.started)
+		if remaining < 0 {
+			remaining = 0
+		}
+	}
+	return remaining
+}
+
+// 今の手の消費時間。
+func (c *Clock) Elapsed() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return 0
+	}
+	return c.ts.Now().Sub(c.started)
+}
+
+func (c *Clock) TimeControl(color shogi.Color) shogi.TimeControl {
+	return c.tc[color]
+}
+
+// 時計が動いている側。止まっていればNO_COLOR。
+func (c *Clock) Turn() shogi.Color {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return shogi.NO_COLOR
+	}
+	return c.turn
+}
@@ -0,0 +1,122 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eru1a/shogi-go"
+)
+
+func TestClockByoyomiAndIncrement(t *testing.T) {
+	ft := NewFakeTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	black := shogi.TimeControl{Main: time.Minute, Byoyomi: 10 * time.Second}
+	white := shogi.TimeControl{Main: time.Minute, Increment: 5 * time.Second}
+	c := NewClock(black, white, 0, ft)
+
+	if err := c.Start(shogi.Black); err != nil {
+		t.Fatal(err)
+	}
+	ft.Advance(20 * time.Second)
+	if got := c.Remaining(shogi.Black); got != 40*time.Second {
+		t.Errorf("running remaining: want 40s, got %v", got)
+	}
+	elapsed, err := c.Switch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed != 20*time.Second || c.Turn() != shogi.White {
+		t.Errorf("switch: got %v, %v", elapsed, c.Turn())
+	}
+
+	ft.Advance(30 * time.Second)
+	if _, err := c.Switch(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Remaining(shogi.White); got != 35*time.Second {
+		t.Errorf("increment: want 35s, got %v", got)
+	}
+
+	// 持ち時間を使い切っても秒読みの間は時間切れにならない
+	ft.Advance(49 * time.Second)
+	if _, err := c.Switch(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Remaining(shogi.Black); got != 0 {
+		t.Errorf("byoyomi: want 0, got %v", got)
+	}
+	if _, flagged := c.Flagged(); flagged {
+		t.Errorf("should not be flagged")
+	}
+}
+
+func TestClockFlag(t *testing.T) {
+	ft := NewFakeTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := shogi.TimeControl{Main: 10 * time.Second, Byoyomi: 5 * time.Second}
+	c := NewClock(tc, tc, 2*time.Second, ft)
+
+	if err := c.Start(shogi.Black); err != nil {
+		t.Fatal(err)
+	}
+	// 猶予の間は時間切れにならない
+	ft.Advance(16 * time.Second)
+	select {
+	case color := <-c.Flag():
+		t.Fatalf("unexpected flag: %v", color)
+	default:
+	}
+	ft.Advance(time.Second)
+	select {
+	case color := <-c.Flag():
+		if color != shogi.Black {
+			t.Errorf("want Black, got %v", color)
+		}
+	default:
+		t.Fatal("want flag")
+	}
+	if _, err := c.Switch(); err == nil {
+		t.Errorf("want error after flag")
+	}
+	if err := c.Start(shogi.White); err != ErrTimeUp {
+		t.Errorf("want ErrTimeUp, got %v", err)
+	}
+}
+
+func TestClockMove(t *testing.T) {
+	ft := NewFakeTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := shogi.TimeControl{Main: time.Minute}
+	c := NewClock(tc, tc, 0, ft)
+	tree := shogi.NewGameTree()
+
+	if err := c.Start(shogi.Black); err != nil {
+		t.Fatal(err)
+	}
+	for i, usi := range []string{"7g7f", "3c3d", "2g2f"} {
+		m, err := shogi.NewMoveFromUSI(usi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ft.Advance(time.Duration(i+1) * time.Second)
+		if err := c.Move(tree, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := tree.Current
+	if n.Time != 3*time.Second || n.TotalTime != 4*time.Second {
+		t.Errorf("time: got %v, %v", n.Time, n.TotalTime)
+	}
+
+	// 時間切れは消費時間と一緒に棋譜に記録される
+	ft2 := NewFakeTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	c2 := NewClock(tc, tc, 0, ft2)
+	if err := c2.Start(shogi.White); err != nil {
+		t.Fatal(err)
+	}
+	ft2.Advance(2 * time.Minute)
+	if err := c2.Move(tree, shogi.ToryoMove); err != ErrTimeUp {
+		t.Fatalf("want ErrTimeUp, got %v", err)
+	}
+	want := shogi.Result{Winner: shogi.Black, Reason: shogi.TimeUpMoveKind}
+	if got := tree.Result(); got != want || tree.Current.Time != time.Minute {
+		t.Errorf("want %v, got %v (%v)", want, got, tree.Current.Time)
+	}
+}
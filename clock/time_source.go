@@ -0,0 +1,115 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 時刻の取得とタイマー。テストではFakeTimeに差し替える。
+type TimeSource interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+type Timer interface {
+	Stop() bool
+}
+
+// 実際の時刻。
+var RealTime TimeSource = realTime{}
+
+type realTime struct{}
+
+func (realTime) Now() time.Time {
+	return time.Now()
+}
+
+func (realTime) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Advanceで進めた分だけ進む時刻。
+type FakeTime struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	ft      *FakeTime
+	at      time.Time
+	f       func()
+	stopped bool
+}
+
+func NewFakeTime(now time.Time) *FakeTime {
+	return &FakeTime{now: now}
+}
+
+func (ft *FakeTime) Now() time.Time {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	return ft.now
+}
+
+func (ft *FakeTime) AfterFunc(d time.Duration, f func()) Timer {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	t := &fakeTimer{ft: ft, at: ft.now.Add(d), f: f}
+	ft.timers = append(ft.timers, t)
+	return t
+}
+
+// 時刻をdだけ進め、その間に期限が来たタイマーを期限の順に呼ぶ。
+func (ft *FakeTime) Advance(d time.Duration) {
+	ft.mu.Lock()
+	end := ft.now.Add(d)
+	sort.SliceStable(ft.timers, func(i, j int) bool {
+		return ft.timers[i].at.Before(ft.timers[j].at)
+	})
+	due := []*fakeTimer{}
+	rest := []*fakeTimer{}
+	for _, t := range ft.timers {
+		if !t.at.After(end) {
+			due = append(due, t)
+		} else {
+			rest = append(rest, t)
+		}
+	}
+	ft.timers = rest
+	ft.mu.Unlock()
+
+	for _, t := range due {
+		ft.mu.Lock()
+		if t.at.After(ft.now) {
+			ft.now = t.at
+		}
+		stopped := t.stopped
+		t.stopped = true
+		ft.mu.Unlock()
+		if !stopped {
+			t.f()
+		}
+	}
+
+	ft.mu.Lock()
+	ft.now = end
+	ft.mu.Unlock()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.ft.mu.Lock()
+	defer t.ft.mu.Unlock()
+	if t.stopped {
+		return false
+	}
+	t.stopped = true
+	for i, timer := range t.ft.timers {
+		if timer == t {
+			t.ft.timers = append(t.ft.timers[:i], t.ft.timers[i+1:]...)
+			break
+		}
+	}
+	return true
+}
@@ -43,6 +43,28 @@ func (c Color) USI() string {
 	}
 }
 
+func NewColorFromCSA(csa string) (Color, error) {
+	switch csa {
+	case "+":
+		return Black, nil
+	case "-":
+		return White, nil
+	default:
+		return NO_COLOR, fmt.Errorf("invalid csa: %s", csa)
+	}
+}
+
+func (c Color) CSA() string {
+	switch c {
+	case Black:
+		return "+"
+	case White:
+		return "-"
+	default:
+		return "_"
+	}
+}
+
 func (c Color) String() string {
 	return c.USI()
 }
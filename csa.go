@@ -0,0 +1,604 @@
+package shogi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSAの$で始まる棋譜情報とKIFのヘッダ名の対応。
+// ここにも持ち時間にもないキーはCSAのキーのままGameInfo.Extraに入れる。
+var csaHeaderKeys = []struct {
+	csa    string
+	header string
+}{
+	{"EVENT", "棋戦"},
+	{"SITE", "場所"},
+	{"START_TIME", "開始日時"},
+	{"END_TIME", "終了日時"},
+	{"OPENING", "戦型"},
+}
+
+var mapTerminalMoveCSA = map[string]Move{
+	"%TORYO":        ToryoMove,
+	"%CHUDAN":       ChudanMove,
+	"%SENNICHITE":   SennichiteMove,
+	"%JISHOGI":      JishogiMove,
+	"%MAX_MOVES":    MaxMovesMove,
+	"%HIKIWAKE":     HikiwakeMove,
+	"%TSUMI":        TsumiMove,
+	"%KACHI":        KachiMove,
+	"%TIME_UP":      TimeUpMove,
+	"%ILLEGAL_MOVE": IllegalMove,
+}
+
+// 駒の総数。P+00ALで残りの駒を駒台に置くときに使う。
+var csaPieceCounts = map[PieceType]int{
+	FU: 18,
+	KY: 4,
+	KE: 4,
+	GI: 4,
+	KI: 4,
+	KA: 2,
+	HI: 2,
+}
+
+// "/"で区切られた複数の棋譜を読み込む。
+func NewGameTreesFromCSA(csa string) ([]*GameTree, error) {
+	trees := []*GameTree{}
+	var b strings.Builder
+	for _, line := range splitLines(csa) {
+		if line == "/" {
+			tree, err := NewGameTreeFromCSA(b.String())
+			if err != nil {
+				return nil, fmt.Errorf("%d-th game: %v", len(trees)+1, err)
+			}
+			trees = append(trees, tree)
+			b.Reset()
+			continue
+		}
+		b.WriteString(line + "\n")
+	}
+	if strings.TrimSpace(b.String()) != "" {
+		tree, err := NewGameTreeFromCSA(b.String())
+		if err != nil {
+			return nil, fmt.Errorf("%d-th game: %v", len(trees)+1, err)
+		}
+		trees = append(trees, tree)
+	}
+	return trees, nil
+}
+
+func NewGameTreeFromCSA(csa string) (*GameTree, error) {
+	r := newCSAReader()
+	for i, line := range splitLines(csa) {
+		if err := r.readLine(line); err != nil {
+			return nil, fmt.Errorf("line %d: %v: %q", i+1, err, line)
+		}
+	}
+	if err := r.setup(); err != nil {
+		return nil, err
+	}
+	return r.tree, nil
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(s, "\n")
+}
+
+type csaReader struct {
+	tree *GameTree
+	info GameInfo
+	// 開始局面。最初の指し手を読むまでここに組み立てる。
+	board    Board
+	hand     *Hand
+	turn     Color
+	hasBoard bool
+	// 開始局面より前のコメント
+	comments []string
+}
+
+func newCSAReader() *csaReader {
+	return &csaReader{
+		info: NewGameInfo(),
+		hand: NewHand(),
+	}
+}
+
+func (r *csaReader) readLine(line string) error {
+	if strings.HasPrefix(line, "'") {
+		r.comment(line[1:])
+		return nil
+	}
+	// ","で区切って1行に複数の文を書ける
+	for _, stmt := range strings.Split(line, ",") {
+		if err := r.readStatement(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *csaReader) comment(c string) {
+	if r.tree == nil {
+		r.comments = append(r.comments, c)
+		return
+	}
+	// floodgateの評価値と読み筋。読めなければ普通のコメントとして扱う。
+	n := r.tree.annotated()
+	if strings.HasPrefix(c, "** ") {
+		if eval, err := newEvaluationFromCSA(c[3:], n.Position); err == nil {
+			n.Eval = eval
+			return
+		}
+	}
+	n.Comments = append(n.Comments, c)
+}
+
+// "30 -3334FU +2726FU"のように評価値の後に読み筋が続く。
+func newEvaluationFromCSA(s string, p *Position) (*Evaluation, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, errors.New("score not found")
+	}
+	score, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	eval := &Evaluation{Score: score}
+	p = p.Clone()
+	for _, f := range fields[1:] {
+		m, err := NewMoveFromCSA(f, p)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.Move(m); err != nil {
+			return nil, err
+		}
+		eval.Pv = append(eval.Pv, m)
+	}
+	return eval, nil
+}
+
+// 詰みは評価値に直して出力する。
+const csaMateScore = 100000
+
+func (e *Evaluation) csa(p *Position) string {
+	score := e.Score
+	if e.IsMate {
+		score = csaMateScore
+		if e.Score < 0 {
+			score = -csaMateScore
+		}
+	}
+	s := "** " + strconv.Itoa(score)
+	p = p.Clone()
+	before := NullSquare
+	for _, m := range e.Pv {
+		moveData := NewMoveData(m, p, before)
+		if err := p.Move(m); err != nil {
+			break
+		}
+		s += " " + moveData.CSA()
+		before = m.To
+	}
+	return s
+}
+
+func (r *csaReader) readStatement(stmt string) error {
+	// 盤面の行は末尾の空白も意味を持つ
+	if strings.HasPrefix(stmt, "P") {
+		return r.readPosition(stmt)
+	}
+	stmt = strings.TrimSpace(stmt)
+	switch {
+	case stmt == "":
+	case stmt[0] == 'V':
+	case strings.HasPrefix(stmt, "N+"):
+		r.info.Black = stmt[2:]
+	case strings.HasPrefix(stmt, "N-"):
+		r.info.White = stmt[2:]
+	case stmt[0] == '$':
+		return r.readInfo(stmt[1:])
+	case stmt == "+" || stmt == "-":
+		if r.tree != nil {
+			return errors.New("turn after moves")
+		}
+		r.turn, _ = NewColorFromCSA(stmt)
+	case stmt[0] == '+' || stmt[0] == '-':
+		return r.readMove(stmt)
+	case stmt[0] == 'T':
+		return r.readTime(stmt[1:])
+	case stmt[0] == '%':
+		return r.readTerminalMove(stmt)
+	default:
+		return errors.New("unknown statement")
+	}
+	return nil
+}
+
+func (r *csaReader) readInfo(info string) error {
+	i := strings.Index(info, ":")
+	if i < 0 {
+		return errors.New("':' not found")
+	}
+	key, value := info[:i], info[i+1:]
+	switch key {
+	case "TIME_LIMIT", "TIME":
+		tc, err := newTimeControlFromCSA(key, value)
+		if err != nil {
+			return err
+		}
+		r.info.TimeControl = tc
+		return nil
+	}
+	for _, k := range csaHeaderKeys {
+		if k.csa == key {
+			r.info.Set(k.header, value)
+			return nil
+		}
+	}
+	r.info.Extra[key] = value
+	return nil
+}
+
+// V2.2の$TIME_LIMIT:00:25+00(時:分+秒読みの秒)と
+// V3.0の$TIME:600+10+0(持ち時間+秒読み+加算の秒)。
+func newTimeControlFromCSA(key, value string) (TimeControl, error) {
+	var tc TimeControl
+	if key == "TIME_LIMIT" {
+		var h, m, s int
+		if _, err := fmt.Sscanf(value, "%d:%d+%d", &h, &m, &s); err != nil {
+			return tc, fmt.Errorf("invalid time limit: %v", err)
+		}
+		tc.Main = time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+		tc.Byoyomi = time.Duration(s) * time.Second
+		return tc, nil
+	}
+	parts := strings.Split(value, "+")
+	if len(parts) != 3 {
+		return tc, fmt.Errorf("invalid time: %s", value)
+	}
+	for i, dst := range []*time.Duration{&tc.Main, &tc.Byoyomi, &tc.Increment} {
+		sec, err := strconv.ParseFloat(parts[i], 64)
+		if err != nil {
+			return tc, fmt.Errorf("invalid time: %v", err)
+		}
+		*dst = time.Duration(sec * float64(time.Second))
+	}
+	return tc, nil
+}
+
+func (r *csaReader) readPosition(stmt string) error {
+	if r.tree != nil {
+		return errors.New("position after moves")
+	}
+	r.hasBoard = true
+	if len(stmt) < 2 {
+		return errors.New("invalid position")
+	}
+	switch {
+	case stmt[1] == 'I':
+		return r.readHirate(stmt[2:])
+	case '1' <= stmt[1] && stmt[1] <= '9':
+		return r.readRank(int(stmt[1]-'1'), stmt[2:])
+	case stmt[1] == '+' || stmt[1] == '-':
+		c, _ := NewColorFromCSA(stmt[1:2])
+		return r.readPieces(c, stmt[2:])
+	}
+	return errors.New("invalid position")
+}
+
+// PIの後ろに駒落ちで取り除く駒("82HI"など)が続く。
+func (r *csaReader) readHirate(s string) error {
+	r.board = *NewPosition().Board
+	for ; len(s) >= 4; s = s[4:] {
+		sq, err := NewSquareFromCSA(s[0:2])
+		if err != nil || sq.IsNull() {
+			return fmt.Errorf("invalid square: %s", s[0:2])
+		}
+		pt, err := NewPieceTypeFromCSA(s[2:4])
+		if err != nil {
+			return err
+		}
+		if r.board.Get(sq).PieceType() != pt {
+			return fmt.Errorf("%s is not on %s", pt.CSA(), sq.CSA())
+		}
+		r.board.Set(sq, NO_PIECE)
+	}
+	if len(s) != 0 {
+		return errors.New("invalid PI")
+	}
+	return nil
+}
+
+// "-KY-KE-GI-KI-OU-KI-GI-KE-KY"のように3文字ずつ9筋分。
+func (r *csaReader) readRank(rank int, s string) error {
+	if len(s) < 27 {
+		s += strings.Repeat(" ", 27-len(s))
+	}
+	for file := 0; file < 9; file++ {
+		csa := s[file*3 : file*3+3]
+		if csa == " * " {
+			r.board[rank][file] = NO_PIECE
+			continue
+		}
+		p, err := NewPieceFromCSA(csa)
+		if err != nil {
+			return err
+		}
+		r.board[rank][file] = p
+	}
+	return nil
+}
+
+// "P+99KI00FU"のように升目と駒の組が続く。升目が00なら持駒、"00AL"なら残りの駒全て。
+func (r *csaReader) readPieces(c Color, s string) error {
+	for ; len(s) >= 4; s = s[4:] {
+		sq, err := NewSquareFromCSA(s[0:2])
+		if err != nil {
+			return err
+		}
+		if sq.IsNull() && s[2:4] == "AL" {
+			r.addRestToHand(c)
+			continue
+		}
+		pt, err := NewPieceTypeFromCSA(s[2:4])
+		if err != nil {
+			return err
+		}
+		if sq.IsNull() {
+			if err := r.hand.Add(pt, c); err != nil {
+				return err
+			}
+			continue
+		}
+		r.board.Set(sq, NewPiece(pt, c))
+	}
+	if len(s) != 0 {
+		return errors.New("invalid pieces")
+	}
+	return nil
+}
+
+func (r *csaReader) addRestToHand(c Color) {
+	rest := make(map[PieceType]int)
+	for pt, n := range csaPieceCounts {
+		rest[pt] = n
+	}
+	for rank := 0; rank < 9; rank++ {
+		for file := 0; file < 9; file++ {
+			if p := r.board[rank][file]; p != NO_PIECE {
+				rest[p.PieceType().Demote()]--
+			}
+		}
+	}
+	for pt := FU; pt <= HI; pt++ {
+		b, _ := r.hand.Get(pt, Black)
+		w, _ := r.hand.Get(pt, White)
+		for i := 0; i < rest[pt]-b-w; i++ {
+			r.hand.Add(pt, c)
+		}
+	}
+}
+
+// 最初の指し手か終局を読んだときに開始局面を確定させる。
+func (r *csaReader) setup() error {
+	if r.tree != nil {
+		return nil
+	}
+	if !r.hasBoard {
+		return errors.New("no initial position")
+	}
+	if r.turn == NO_COLOR {
+		return errors.New("no turn")
+	}
+	b := r.board
+	r.tree = NewGameTreeFromPosition(&Position{Board: &b, Turn: r.turn, Hand: r.hand, Ply: 0})
+	if r.info.Handicap == "" {
+		r.info.Handicap = handicapName(r.tree.Root.Position)
+	}
+	r.tree.Info = r.info
+	r.tree.Root.Comments = r.comments
+	return nil
+}
+
+func (r *csaReader) readMove(stmt string) error {
+	if err := r.setup(); err != nil {
+		return err
+	}
+	m, err := NewMoveFromCSA(stmt, r.tree.Current.Position)
+	if err != nil {
+		return err
+	}
+	return r.tree.readMove(m)
+}
+
+// "+7776FU"のような形式。成りかどうかは局面から判断する。
+func NewMoveFromCSA(csa string, p *Position) (Move, error) {
+	if len(csa) != 7 {
+		return NullMove, errors.New("length of move should be 7")
+	}
+	c, err := NewColorFromCSA(csa[0:1])
+	if err != nil {
+		return NullMove, err
+	}
+	if c != p.Turn {
+		return NullMove, fmt.Errorf("not %v's turn", c)
+	}
+	from, err := NewSquareFromCSA(csa[1:3])
+	if err != nil {
+		return NullMove, err
+	}
+	to, err := NewSquareFromCSA(csa[3:5])
+	if err != nil || to.IsNull() {
+		return NullMove, fmt.Errorf("invalid square: %s", csa[3:5])
+	}
+	pt, err := NewPieceTypeFromCSA(csa[5:7])
+	if err != nil {
+		return NullMove, err
+	}
+
+	if from.IsNull() {
+		return NewDropMove(pt, to), nil
+	}
+	moved := p.Get(from).PieceType()
+	switch {
+	case moved == pt:
+		return NewNormalMove(from, to, false), nil
+	case !moved.IsPromoted() && moved.Promote() == pt:
+		return NewNormalMove(from, to, true), nil
+	}
+	return NullMove, fmt.Errorf("%s is not on %s", pt.CSA(), from.CSA())
+}
+
+// V2.2は秒の整数、V3.0は小数も書ける。
+func (r *csaReader) readTime(s string) error {
+	if r.tree == nil {
+		return errors.New("time before moves")
+	}
+	sec, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	r.tree.annotated().SetTime(time.Duration(sec * float64(time.Second)))
+	return nil
+}
+
+func (r *csaReader) readTerminalMove(stmt string) error {
+	if err := r.setup(); err != nil {
+		return err
+	}
+	// 手番に関わらず反則した側の負け
+	if stmt == "%+ILLEGAL_ACTION" || stmt == "%-ILLEGAL_ACTION" {
+		if err := r.tree.readMove(IllegalMove); err != nil {
+			return err
+		}
+		r.tree.Current.MoveData.Color, _ = NewColorFromCSA(stmt[1:2])
+		return nil
+	}
+	m, ok := mapTerminalMoveCSA[stmt]
+	if !ok {
+		return errors.New("unknown special move")
+	}
+	return r.tree.readMove(m)
+}
+
+// 本譜をCSA V2.2形式で出力する。
+func (t *GameTree) CSA() string {
+	var s strings.Builder
+
+	s.WriteString("V2.2\n")
+	if t.Info.Black != "" {
+		s.WriteString("N+" + t.Info.Black + "\n")
+	}
+	if t.Info.White != "" {
+		s.WriteString("N-" + t.Info.White + "\n")
+	}
+	for _, line := range t.csaInfo() {
+		s.WriteString(line + "\n")
+	}
+
+	s.WriteString(t.Root.Position.csaPosition())
+	writeCSAComments(&s, t.Root.Comments)
+
+	for n := t.Root.Next; n != nil; n = n.Next {
+		m := n.MoveData
+		if c, ok := m.illegalAction(n.Position.Turn); ok {
+			s.WriteString("%" + c.CSA() + "ILLEGAL_ACTION\n")
+		} else {
+			s.WriteString(m.CSA() + "\n")
+		}
+		if n.Time != 0 {
+			s.WriteString(fmt.Sprintf("T%d\n", int(n.Time/time.Second)))
+		}
+		if n.Eval != nil {
+			s.WriteString("'" + n.Eval.csa(n.Position) + "\n")
+		}
+		writeCSAComments(&s, n.Comments)
+	}
+
+	return s.String()
+}
+
+func (t *GameTree) csaInfo() []string {
+	lines := []string{}
+	header := t.Info.Header()
+	for _, k := range csaHeaderKeys {
+		if value, ok := header[k.header]; ok {
+			lines = append(lines, "$"+k.csa+":"+value)
+		}
+	}
+	if tc := t.Info.TimeControl; !tc.IsZero() {
+		if tc.Increment == 0 && tc.Main%time.Minute == 0 && tc.Byoyomi%time.Second == 0 {
+			lines = append(lines, fmt.Sprintf("$TIME_LIMIT:%02d:%02d+%02d",
+				tc.Main/time.Hour, tc.Main%time.Hour/time.Minute, tc.Byoyomi/time.Second))
+		} else {
+			lines = append(lines, fmt.Sprintf("$TIME:%v+%v+%v",
+				tc.Main.Seconds(), tc.Byoyomi.Seconds(), tc.Increment.Seconds()))
+		}
+	}
+	// CSAから読み込んだ未知のキーはそのまま出力する
+	others := []string{}
+	for key := range t.Info.Extra {
+		if isCSAInfoKey(key) {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	for _, key := range others {
+		lines = append(lines, "$"+key+":"+t.Info.Extra[key])
+	}
+	return lines
+}
+
+func isCSAInfoKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if !('A' <= key[i] && key[i] <= 'Z' || '0' <= key[i] && key[i] <= '9' || key[i] == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func writeCSAComments(s *strings.Builder, comments []string) {
+	for _, c := range comments {
+		s.WriteString("'" + c + "\n")
+	}
+}
+
+func (p *Position) csaPosition() string {
+	var s strings.Builder
+
+	if p.Board.SFEN() == NewPosition().Board.SFEN() && p.Hand.SFEN() == "-" {
+		s.WriteString("PI\n")
+	} else {
+		for rank := 0; rank < 9; rank++ {
+			s.WriteString(fmt.Sprintf("P%d", rank+1))
+			for file := 0; file < 9; file++ {
+				s.WriteString(p.Board[rank][file].CSA())
+			}
+			s.WriteString("\n")
+		}
+		for _, c := range []Color{Black, White} {
+			hand := ""
+			for pt := HI; pt >= FU; pt-- {
+				n, _ := p.HandGet(pt, c)
+				hand += strings.Repeat("00"+pt.CSA(), n)
+			}
+			if hand != "" {
+				s.WriteString("P" + c.CSA() + hand + "\n")
+			}
+		}
+	}
+	s.WriteString(p.Turn.CSA() + "\n")
+
+	return s.String()
+}
@@ -0,0 +1,340 @@
+package shogi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGameTreeFromCSA(t *testing.T) {
+	tests := []struct {
+		csa      string
+		header   map[string]string
+		sfens    []string
+		kifs     []string
+		times    []time.Duration
+		comments [][]string
+	}{
+		{
+			csa: `V2.2
+N+Sente
+N-Gote
+$EVENT:test
+$START_TIME:2020/01/02 03:04:05
+$TIME_LIMIT:00:25+30
+$NOTE:memo
+'start
+PI
++
++7776FU
+T3
+'first
+-3334FU,T5
++8822UM
+T1
+-3122GI
+T10
++0055KA
+%TORYO
+`,
+			header: map[string]string{
+				"先手":   "Sente",
+				"後手":   "Gote",
+				"棋戦":   "test",
+				"開始日時": "2020/01/02 03:04:05",
+				"持ち時間": "25分+秒読み30秒",
+				"手合割":  "平手",
+				"NOTE": "memo",
+			},
+			sfens: []string{
+				"lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1",
+				"lnsgkgsnl/1r5b1/ppppppppp/9/9/2P6/PP1PPPPPP/1B5R1/LNSGKGSNL w - 2",
+				"lnsgkgsnl/1r5b1/pppppp1pp/6p2/9/2P6/PP1PPPPPP/1B5R1/LNSGKGSNL b - 3",
+				"lnsgkgsnl/1r5+B1/pppppp1pp/6p2/9/2P6/PP1PPPPPP/7R1/LNSGKGSNL w B 4",
+				"lnsgkg1nl/1r5s1/pppppp1pp/6p2/9/2P6/PP1PPPPPP/7R1/LNSGKGSNL b Bb 5",
+				"lnsgkg1nl/1r5s1/pppppp1pp/6p2/4B4/2P6/PP1PPPPPP/7R1/LNSGKGSNL w b 6",
+				"lnsgkg1nl/1r5s1/pppppp1pp/6p2/4B4/2P6/PP1PPPPPP/7R1/LNSGKGSNL w b 6",
+			},
+			kifs:     []string{"開始局面", "７六歩(77)", "３四歩(33)", "２二角成(88)", "同銀(31)", "５五角打", "投了"},
+			times:    []time.Duration{0, 3 * time.Second, 5 * time.Second, time.Second, 10 * time.Second, 0, 0},
+			comments: [][]string{{"start"}, {"first"}, nil, nil, nil, nil, nil},
+		},
+		{
+			// 駒落ちとV3.0の小数の消費時間
+			csa: `V3.0
+PI82HI22KA
+-
+-3334FU
+T1.5
+%CHUDAN
+`,
+			header: map[string]string{"手合割": "二枚落ち"},
+			sfens: []string{
+				"lnsgkgsnl/9/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1",
+				"lnsgkgsnl/9/pppppp1pp/6p2/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 2",
+				"lnsgkgsnl/9/pppppp1pp/6p2/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 2",
+			},
+			kifs:     []string{"開始局面", "３四歩(33)", "中断"},
+			times:    []time.Duration{0, 1500 * time.Millisecond, 0},
+			comments: [][]string{nil, nil, nil},
+		},
+		{
+			// 詰将棋
+			csa: `P1 *  *  *  *  *  * -KE-OU-KY
+P2 *  *  *  *  *  *  *  *  *
+P3 *  *  *  *  *  * +TO-FU *
+P4 *  *  *  *  *  *  *  *  *
+P5 *  *  *  *  *  *  *  *  *
+P6 *  *  *  *  *  *  *  *  *
+P7 *  *  *  *  *  *  *  *  *
+P8 *  *  *  *  *  *  *  *  *
+P9 *  *  *  *  *  *  *  *  *
+P+00KI
+P-00AL
++
++0012KI
+%TSUMI
+`,
+			header: map[string]string{},
+			sfens: []string{
+				"6nkl/9/6+Pp1/9/9/9/9/9/9 b G2r2b3g4s3n3l16p 1",
+				"6nkl/8G/6+Pp1/9/9/9/9/9/9 w 2r2b3g4s3n3l16p 2",
+				"6nkl/8G/6+Pp1/9/9/9/9/9/9 w 2r2b3g4s3n3l16p 2",
+			},
+			kifs:     []string{"開始局面", "１二金打", "詰み"},
+			times:    []time.Duration{0, 0, 0},
+			comments: [][]string{nil, nil, nil},
+		},
+	}
+
+	for _, test := range tests {
+		tree, err := NewGameTreeFromCSA(test.csa)
+		if err != nil {
+			t.Fatalf("NewGameTreeFromCSA(%s): %v", test.csa, err)
+		}
+		if header := tree.Info.Header(); !reflect.DeepEqual(header, test.header) {
+			t.Errorf("header: want %v, got %v", test.header, header)
+		}
+		sfens := []string{}
+		kifs := []string{}
+		times := []time.Duration{}
+		comments := [][]string{}
+		for n := tree.Root; n != nil; n = n.Next {
+			sfens = append(sfens, n.Position.SFEN())
+			kifs = append(kifs, n.MoveData.KIF())
+			times = append(times, n.Time)
+			comments = append(comments, n.Comments)
+		}
+		if !reflect.DeepEqual(sfens, test.sfens) {
+			t.Errorf("sfens:\nwant %v\ngot %v", test.sfens, sfens)
+		}
+		if !reflect.DeepEqual(kifs, test.kifs) {
+			t.Errorf("kifs:\nwant %v\ngot %v", test.kifs, kifs)
+		}
+		if !reflect.DeepEqual(times, test.times) {
+			t.Errorf("times:\nwant %v\ngot %v", test.times, times)
+		}
+		if !reflect.DeepEqual(comments, test.comments) {
+			t.Errorf("comments:\nwant %v\ngot %v", test.comments, comments)
+		}
+
+		// 書き出して読み直しても同じになる
+		csa := tree.CSA()
+		tree2, err := NewGameTreeFromCSA(csa)
+		if err != nil {
+			t.Fatalf("NewGameTreeFromCSA(%s): %v", csa, err)
+		}
+		if tree2.CSA() != csa {
+			t.Errorf("round trip:\nwant %s\ngot %s", csa, tree2.CSA())
+		}
+	}
+}
+
+func TestGameTreeCSA(t *testing.T) {
+	tree := NewGameTree()
+	tree.Info.Black = "A"
+	tree.Info.White = "B"
+	tree.Info.Event = "test"
+	tree.Info.Handicap = "平手"
+	tree.Info.TimeControl = TimeControl{Main: 10 * time.Minute, Increment: 5 * time.Second}
+	tree.Info.Extra["戦法"] = "矢倉"
+	for _, usi := range []string{"7g7f", "3c3d"} {
+		m, _ := NewMoveFromUSI(usi)
+		if err := tree.Move(m); err != nil {
+			t.Fatal(err)
+		}
+		tree.Current.Time = 2 * time.Second
+	}
+	tree.Current.Comments = []string{"comment"}
+	if err := tree.Move(ToryoMove); err != nil {
+		t.Fatal(err)
+	}
+	want := `V2.2
+N+A
+N-B
+$EVENT:test
+$TIME:600+0+5
+PI
++
++7776FU
+T2
+-3334FU
+T2
+'comment
+%TORYO
+`
+	if csa := tree.CSA(); csa != want {
+		t.Errorf("CSA():\nwant %s\ngot %s", want, csa)
+	}
+}
+
+func TestGameTreesFromCSA(t *testing.T) {
+	csa := `PI
++
++7776FU
+%TORYO
+/
+PI
++
++2726FU
+-8384FU
+%SENNICHITE
+`
+	trees, err := NewGameTreesFromCSA(csa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trees) != 2 {
+		t.Fatalf("want 2 games, got %d", len(trees))
+	}
+	for i, want := range []MoveKind{ToryoMoveKind, SennichiteMoveKind} {
+		tree := trees[i]
+		tree.GotoNth(10)
+		if tree.Current.MoveData.Kind != want {
+			t.Errorf("%d-th game: want %v, got %v", i, want, tree.Current.MoveData.Kind)
+		}
+	}
+}
+
+func TestGameTreeFromCSAError(t *testing.T) {
+	tests := []string{
+		// 開始局面がない
+		"+7776FU\n",
+		// 手番が違う
+		"PI\n+\n-3334FU\n",
+		// 駒が違う
+		"PI\n+\n+7776KY\n",
+		// 非合法手
+		"PI\n+\n+7775FU\n",
+		// 終局後の指し手
+		"PI\n+\n%TORYO\n+7776FU\n",
+	}
+	for _, csa := range tests {
+		if _, err := NewGameTreeFromCSA(csa); err == nil {
+			t.Errorf("NewGameTreeFromCSA(%q): want error", csa)
+		}
+	}
+}
+
+func TestGameTreeCSAAnnotation(t *testing.T) {
+	csa := `PI
++
++7776FU
+T10
+'** 30 -3334FU +2726FU
+-3334FU
+T5
+'** -10
+'comment
++2726FU
+T20
+'** foo
+`
+	tree, err := NewGameTreeFromCSA(csa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n1 := tree.Root.Next
+	n2 := n1.Next
+	n3 := n2.Next
+	if n1.Eval == nil || n1.Eval.Score != 30 || len(n1.Eval.Pv) != 2 || n1.Eval.Pv[1].USI() != "2g2f" {
+		t.Errorf("eval: got %v", n1.Eval)
+	}
+	if n2.Eval == nil || n2.Eval.Score != -10 || len(n2.Eval.Pv) != 0 {
+		t.Errorf("eval: got %v", n2.Eval)
+	}
+	if want := []string{"comment"}; !reflect.DeepEqual(n2.Comments, want) {
+		t.Errorf("comments: want %v, got %v", want, n2.Comments)
+	}
+	// 読めない評価値はコメントのまま
+	if want := []string{"** foo"}; n3.Eval != nil || !reflect.DeepEqual(n3.Comments, want) {
+		t.Errorf("comments: want %v, got %v, %v", want, n3.Comments, n3.Eval)
+	}
+	totals := []time.Duration{n1.TotalTime, n2.TotalTime, n3.TotalTime}
+	if want := []time.Duration{10 * time.Second, 5 * time.Second, 30 * time.Second}; !reflect.DeepEqual(totals, want) {
+		t.Errorf("total times: want %v, got %v", want, totals)
+	}
+
+	if out := tree.CSA(); out != "V2.2\n"+csa {
+		t.Errorf("CSA():\nwant %s\ngot %s", "V2.2\n"+csa, out)
+	}
+}
+
+func TestGameTreeFromCSAKachi(t *testing.T) {
+	// ルールでは宣言の条件を満たさない局面でも、棋譜に書かれた入玉勝ちのまま読む
+	tree, err := NewGameTreeFromCSA("PI\n+\n+7776FU\n-3334FU\n%KACHI\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Result{Winner: Black, Reason: KachiMoveKind}
+	if got := tree.Result(); got != want {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	// 対局中の宣言はルールで判定する
+	played, err := NewGameTreeFromUSI("startpos moves 7g7f 3c3d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := played.Move(KachiMove); err != nil {
+		t.Fatal(err)
+	}
+	want = Result{Winner: White, Reason: IllegalMoveKind}
+	if got := played.Result(); got != want {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestGameTreeCSAMatingMoveAnnotation(t *testing.T) {
+	tree, err := NewGameTreeFromSFEN("4k4/9/4P4/9/9/9/9/9/4K4 b G 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := NewMoveFromUSI("G*5b")
+	if err := tree.Move(m); err != nil {
+		t.Fatal(err)
+	}
+	mate := tree.Root.Next
+	mate.SetTime(7 * time.Second)
+	mate.Comments = []string{"c"}
+	mate.Eval = &Evaluation{Score: 1, IsMate: true}
+
+	// 自動で追加された詰みではなく指し手に付く
+	for _, csa := range []string{tree.CSA(), strings.Replace(tree.CSA(), "%TSUMI", "%TORYO", 1)} {
+		read, err := NewGameTreeFromCSA(csa)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := read.Root.Next
+		if n.Time != 7*time.Second || !reflect.DeepEqual(n.Comments, []string{"c"}) || n.Eval == nil {
+			t.Errorf("move: got %v %v %v\n%s", n.Time, n.Comments, n.Eval, csa)
+		}
+		if n.Next == nil || n.Next.Time != 0 || len(n.Next.Comments) != 0 || n.Next.Eval != nil {
+			t.Errorf("terminal: got %+v\n%s", n.Next, csa)
+		}
+		if out := read.CSA(); out != csa {
+			t.Errorf("want\n%s\ngot\n%s", csa, out)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/eru1a/shogi-go"
+)
+
+// 1つの読み筋の最新の状態。評価値と上界・下界は先手から見たもの。
+type PV struct {
+	MultiPv  int
+	Depth    int
+	SelDepth int
+	Score    int
+	IsMate   bool
+	// 評価値が上界か下界か
+	Upperbound bool
+	Lowerbound bool
+	Nodes      int
+	Nps        int
+	Time       time.Duration
+	// 局面から指せる所まで
+	Moves []shogi.Move
+}
+
+// 解析の途中経過。
+type Analysis struct {
+	// 解析した局面
+	Position *shogi.Position
+	// multipvの順
+	PVs []PV
+	// 探索が終わったらtrueになり、BestMoveとPonderが設定される
+	Done     bool
+	BestMove shogi.Move
+	Ponder   shogi.Move
+	// エンジンが終了したなどで最後まで解析できなかった
+	Err error
+}
+
+// stopを送ってもbestmoveが届かなかった
+var ErrNoBestMove = errors.New("engine: no bestmove after stop")
+
+// pを解析し、infoを受け取るたびにまとめた途中経過を送るチャンネルを返す。
+// 受け取り手が遅れたら途中経過は最新のものだけを送る。最後はbestmoveを含む途中経過を送って閉じる。
+// limitsがゼロならgo infiniteで、ctxが終わったらstopを送る。
+// stopを送ってからstopTimeoutの間にbestmoveが届かなければ、ErrNoBestMoveを付けた途中経過を送って閉じる。
+func (e *Engine) Analyze(ctx context.Context, p *shogi.Position, limits GoParams) (<-chan Analysis, error) {
+	if e.State() == Thinking {
+		return nil, errors.New("engine: already thinking")
+	}
+	if limits.IsZero() {
+		limits.Infinite = true
+	}
+
+	events, unsubscribe := e.Subscribe()
+	if err := e.SetPosition(p, nil); err != nil {
+		unsubscribe()
+		return nil, err
+	}
+	search, err := e.Go(limits)
+	if err != nil {
+		unsubscribe()
+		return nil, err
+	}
+	p = p.Clone()
+
+	c := make(chan Analysis)
+	go func() {
+		defer close(c)
+		defer unsubscribe()
+		e.analyze(ctx, p, search, events, c)
+	}()
+	return c, nil
+}
+
+func (e *Engine) analyze(ctx context.Context, p *shogi.Position, search int, events <-chan Event, c chan<- Analysis) {
+	pvs := map[int]PV{}
+	var pending *Analysis
+	stopped := false
+	done := ctx.Done()
+	var timeout <-chan time.Time
+
+	for {
+		var out chan<- Analysis
+		var next Analysis
+		if pending != nil {
+			out = c
+			next = *pending
+		}
+
+		select {
+		case ev := <-events:
+			// 前の探索の出力は捨てる
+			if ev.Type != ExitEvent && ev.Search != search {
+				continue
+			}
+			switch ev.Type {
+			case InfoEvent:
+				if ev.Info.IsCp || ev.Info.IsMate {
+					pvs[ev.Info.MultiPv] = newPV(ev, p.Turn)
+					pending = snapshot(p, pvs)
+				}
+			case BestMoveEvent:
+				a := snapshot(p, pvs)
+				a.Done = true
+				a.BestMove = ev.Move
+				a.Ponder = ev.Ponder
+				finish(c, *a, stopped)
+				return
+			case ExitEvent:
+				a := snapshot(p, pvs)
+				a.Done = true
+				a.Err = ErrExited
+				finish(c, *a, stopped)
+				return
+			}
+		case out <- next:
+			pending = nil
+		case <-done:
+			// bestmoveが来るまで読み続ける
+			e.SendStop()
+			stopped = true
+			done = nil
+			timer := time.NewTimer(stopTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		case <-timeout:
+			a := snapshot(p, pvs)
+			a.Err = ErrNoBestMove
+			finish(c, *a, stopped)
+			return
+		}
+	}
+}
+
+// 中断した後に最後の途中経過の受け取りを待つ時間
+const finishTimeout = time.Second
+
+// stopを送ってからbestmoveを待つ時間。テストでは短くする。
+var stopTimeout = 10 * time.Second
+
+// 最後の途中経過を送る。中断したなら受け取り手がいなくなっていることがあるので少しだけ待つ。
+func finish(c chan<- Analysis, a Analysis, stopped bool) {
+	if !stopped {
+		c <- a
+		return
+	}
+	timer := time.NewTimer(finishTimeout)
+	defer timer.Stop()
+	select {
+	case c <- a:
+	case <-timer.C:
+	}
+}
+
+func newPV(ev Event, turn shogi.Color) PV {
+	info := ev.Info
+	moves, _ := ev.Pv()
+	pv := PV{
+		MultiPv:    info.MultiPv,
+		Depth:      info.Depth,
+		SelDepth:   info.SelDepth,
+		Score:      info.ScoreCp,
+		IsMate:     info.IsMate,
+		Upperbound: info.Upperbound,
+		Lowerbound: info.Lowerbound,
+		Nodes:      info.Nodes,
+		Nps:        info.Nps,
+		Time:       time.Duration(info.Time) * time.Millisecond,
+		Moves:      moves,
+	}
+	if info.IsMate {
+		pv.Score = info.ScoreMate
+	}
+	// 後手から見た値を反転すると上界と下界も入れ替わる
+	if turn == shogi.White {
+		pv.Score = -pv.Score
+		pv.Upperbound, pv.Lowerbound = pv.Lowerbound, pv.Upperbound
+	}
+	return pv
+}
+
+func snapshot(p *shogi.Position, pvs map[int]PV) *Analysis {
+	a := &Analysis{Position: p}
+	for _, pv := range pvs {
+		a.PVs = append(a.PVs, pv)
+	}
+	sort.Slice(a.PVs, func(i, j int) bool {
+		return a.PVs[i].MultiPv < a.PVs[j].MultiPv
+	})
+	return a
+}
@@ -0,0 +1,96 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eru1a/shogi-go"
+	"github.com/eru1a/shogi-go/engine"
+	"github.com/eru1a/shogi-go/engine/enginetest"
+)
+
+func TestEngineAnalyze(t *testing.T) {
+	f := &enginetest.Fake{OnGo: enginetest.Reply(
+		"info depth 1 multipv 1 score cp 100 nodes 10 pv 3c3d",
+		"info depth 1 multipv 2 score cp 50 pv 8c8d",
+		"info depth 2 multipv 1 score cp 120 lowerbound nodes 20 nps 1000 time 20 pv 3c3d 2g2f",
+		"info string hello",
+		"bestmove 3c3d ponder 2g2f",
+	)}
+	e := f.Engine()
+	defer e.Close(context.Background())
+
+	// 後手番なので評価値と上界・下界を反転する
+	p, err := shogi.NewPositionFromSFEN("lnsgkgsnl/1r5b1/ppppppppp/9/9/2P6/PP1PPPPPP/1B5R1/LNSGKGSNL w - 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	c, err := e.Analyze(ctx, p, engine.GoParams{Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last engine.Analysis
+	for a := range c {
+		last = a
+	}
+
+	if !last.Done || last.Err != nil || last.BestMove.USI() != "3c3d" || last.Ponder.USI() != "2g2f" {
+		t.Fatalf("got %+v", last)
+	}
+	if len(last.PVs) != 2 {
+		t.Fatalf("want 2 pvs, got %+v", last.PVs)
+	}
+	first := last.PVs[0]
+	if first.Depth != 2 || first.Score != -120 || !first.Upperbound || first.Lowerbound ||
+		first.Nodes != 20 || first.Nps != 1000 || first.Time != 20*time.Millisecond || len(first.Moves) != 2 {
+		t.Errorf("pv 1: got %+v", first)
+	}
+	if second := last.PVs[1]; second.MultiPv != 2 || second.Score != -50 {
+		t.Errorf("pv 2: got %+v", second)
+	}
+	if got := f.Commands(); got[len(got)-1] != "go depth 2" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestEngineAnalyzeCancel(t *testing.T) {
+	f := &enginetest.Fake{
+		OnGo: func(position, command string) []enginetest.Line {
+			return []enginetest.Line{
+				{Text: "info depth 1 score mate 3 pv 7g7f"},
+				{Text: "info depth 2 score cp 0 pv 7g7f", Delay: time.Hour},
+			}
+		},
+		StopBestMove: "bestmove 7g7f",
+	}
+	e := f.Engine()
+	defer e.Close(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := e.Analyze(ctx, shogi.NewPosition(), engine.GoParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := <-c; len(a.PVs) != 1 || !a.PVs[0].IsMate || a.PVs[0].Score != 3 {
+		t.Errorf("got %+v", a)
+	}
+
+	// 中断したらstopを送ってbestmoveで終わる
+	cancel()
+	var last engine.Analysis
+	for a := range c {
+		last = a
+	}
+	if !last.Done || last.BestMove.USI() != "7g7f" {
+		t.Errorf("got %+v", last)
+	}
+	want := []string{"position startpos", "go infinite", "stop"}
+	for i, command := range f.Commands() {
+		if command != want[i] {
+			t.Errorf("want %v, got %v", want, f.Commands())
+		}
+	}
+}
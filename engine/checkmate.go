@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type CheckmateStatus uint8
+
+const (
+	// 詰みが見つかった
+	CheckmateFound CheckmateStatus = iota
+	// 不詰み
+	CheckmateNoMate
+	// 時間内に見つからなかった
+	CheckmateTimeout
+	// 詰み探索に対応していない
+	CheckmateNotImplemented
+)
+
+func (s CheckmateStatus) String() string {
+	switch s {
+	case CheckmateFound:
+		return "mate"
+	case CheckmateNoMate:
+		return "nomate"
+	case CheckmateTimeout:
+		return "timeout"
+	}
+	return "notimplemented"
+}
+
+// go mateへの応答。
+type USICheckmate struct {
+	Status CheckmateStatus
+	// 詰みの手順
+	Moves []string
+}
+
+// "checkmate G*5b 5a5b"や"checkmate nomate"のような形式。
+func NewUSICheckmate(line string) (USICheckmate, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "checkmate" {
+		return USICheckmate{}, fmt.Errorf("invalid checkmate: %s", line)
+	}
+	switch fields[1] {
+	case "nomate":
+		return USICheckmate{Status: CheckmateNoMate}, nil
+	case "timeout":
+		return USICheckmate{Status: CheckmateTimeout}, nil
+	case "notimplemented":
+		return USICheckmate{Status: CheckmateNotImplemented}, nil
+	}
+	return USICheckmate{Status: CheckmateFound, Moves: fields[1:]}, nil
+}
+
+// 詰み探索を始める。結果はCheckmateEventで届く。timeoutが0なら時間無制限。
+func (e *Engine) GoMate(timeout time.Duration) error {
+	p := GoParams{Mate: timeout}
+	if timeout <= 0 {
+		p = GoParams{MateInfinite: true}
+	}
+	_, err := e.Go(p)
+	return err
+}
@@ -2,14 +2,23 @@ package engine
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/eru1a/shogi-go"
 )
 
+var ErrExited = errors.New("engine exited")
+
 type EngineState uint8
 
 const (
@@ -45,20 +54,29 @@ func NewUSIInfo(line string) (USIInfo, error) {
 		MultiPv: 1,
 	}
 
-	scanner := bufio.NewScanner(strings.NewReader(line))
-	scanner.Split(bufio.ScanWords)
+	fields := strings.Fields(line)
+
+	// i番目の次の値。なければエラー。
+	value := func(i int) (string, error) {
+		if i+1 >= len(fields) {
+			return "", fmt.Errorf("invalid info: missing value of %s: %s", fields[i], line)
+		}
+		return fields[i+1], nil
+	}
 
 	atoi := func(a string) (int, error) {
 		sign := 1
-		if a[0] == '+' {
+		if len(a) > 0 && a[0] == '+' {
 			a = a[1:]
-		}
-		if a[0] == '-' {
+		} else if len(a) > 0 && a[0] == '-' {
 			sign = -1
 			a = a[1:]
 		}
 		i, err := strconv.Atoi(a)
-		return sign * i, err
+		if err != nil {
+			return 0, fmt.Errorf("invalid info: %s", line)
+		}
+		return sign * i, nil
 	}
 
 	isMove := func(m string) bool {
@@ -75,90 +93,77 @@ func NewUSIInfo(line string) (USIInfo, error) {
 		return false
 	}
 
-	var err error
-	for scanner.Scan() {
-		switch scanner.Text() {
+	ints := map[string]*int{
+		"multipv":  &info.MultiPv,
+		"depth":    &info.Depth,
+		"seldepth": &info.SelDepth,
+		"nodes":    &info.Nodes,
+		"nps":      &info.Nps,
+		"time":     &info.Time,
+		"hashfull": &info.HashFull,
+	}
+
+	for i := 0; i < len(fields); i++ {
+		switch f := fields[i]; f {
 		case "info":
-		case "multipv":
-			scanner.Scan()
-			info.MultiPv, err = atoi(scanner.Text())
-			if err != nil {
-				break
-			}
-		case "cp":
-			scanner.Scan()
-			info.ScoreCp, err = atoi(scanner.Text())
-			if err != nil {
-				break
-			}
-			info.IsCp = true
-		case "mate":
-			scanner.Scan()
-			info.ScoreMate, err = atoi(scanner.Text())
-			if err != nil {
-				break
-			}
-			info.IsMate = true
-		case "depth":
-			scanner.Scan()
-			info.Depth, err = atoi(scanner.Text())
+		case "cp", "mate":
+			v, err := value(i)
 			if err != nil {
-				break
+				return USIInfo{}, err
 			}
-		case "seldepth":
-			scanner.Scan()
-			info.SelDepth, err = atoi(scanner.Text())
-			if err != nil {
-				break
+			i++
+			// "mate +"と"mate -"は手数の分からない詰み
+			if f == "mate" && (v == "+" || v == "-") {
+				info.ScoreMate = 1
+				if v == "-" {
+					info.ScoreMate = -1
+				}
+				info.IsMate = true
+				continue
 			}
-		case "nodes":
-			scanner.Scan()
-			info.Nodes, err = atoi(scanner.Text())
+			n, err := atoi(v)
 			if err != nil {
-				break
+				return USIInfo{}, err
 			}
-		case "nps":
-			scanner.Scan()
-			info.Nps, err = atoi(scanner.Text())
-			if err != nil {
-				break
+			if f == "cp" {
+				info.ScoreCp = n
+				info.IsCp = true
+			} else {
+				info.ScoreMate = n
+				info.IsMate = true
 			}
-		case "time":
-			scanner.Scan()
-			info.Time, err = atoi(scanner.Text())
+		case "multipv", "depth", "seldepth", "nodes", "nps", "time", "hashfull":
+			v, err := value(i)
 			if err != nil {
-				break
+				return USIInfo{}, err
 			}
-		case "hashfull":
-			scanner.Scan()
-			info.HashFull, err = atoi(scanner.Text())
-			if err != nil {
-				break
+			i++
+			if *ints[f], err = atoi(v); err != nil {
+				return USIInfo{}, err
 			}
 		case "upperbound":
 			info.Upperbound = true
 		case "lowerbound":
 			info.Lowerbound = true
 		case "currmove":
-			scanner.Scan()
-			info.CurrMove = scanner.Text()
+			v, err := value(i)
+			if err != nil {
+				return USIInfo{}, err
+			}
+			i++
+			info.CurrMove = v
 		case "pv":
-			// 最後まで読む
-			for scanner.Scan() {
-				move := scanner.Text()
-				if !isMove(move) {
-					break
-				}
-				info.Pv = append(info.Pv, move)
+			// 指し手でないものが来るまで読む
+			for i+1 < len(fields) && isMove(fields[i+1]) {
+				i++
+				info.Pv = append(info.Pv, fields[i])
 			}
-			break
-		default:
+		case "string":
+			// 残りは全て文字列
+			return info, nil
 		}
 	}
 
-	if err != nil {
-		return USIInfo{}, err
-	}
 	return info, nil
 }
 
@@ -189,126 +194,513 @@ func NewUSIBestMove(line string) (USIBestMove, error) {
 	return bestMove, nil
 }
 
-type Engine struct {
-	cmd    *exec.Cmd
-	stdout *bufio.Reader
-	stdin  *bufio.Writer
+// エンジンの終了状態。
+type ExitStatus struct {
+	// cmd.Waitのエラー。正常終了ならnil。ストリームなら読み込みのエラー。
+	Err error
+	// ストリームなら-1
+	ExitCode int
+	// 標準エラー出力の最後の部分
+	Stderr string
+}
 
-	Name   string
-	Author string
-	State  EngineState
+// エンジンのプロセスの起動方法。
+type EngineConfig struct {
+	Path string
+	Args []string
+	// nilなら作った時点の環境変数
+	Env []string
+	// 空ならPathのあるディレクトリ
+	Dir string
+	// 標準エラー出力のコピー先。最後の部分はnilでもExitStatusに残る。
+	Stderr io.Writer
+}
 
-	InfoC     chan<- USIInfo
-	BestMoveC chan<- USIBestMove
+type Engine struct {
+	// ストリームから作ったエンジンならnil
+	config *EngineConfig
+
+	// stdinへの書き込み。エンジンが読まないと書き込みが返らないので、muとは別にする。
+	writeMu sync.Mutex
+
+	mu    sync.Mutex
+	stdin *bufio.Writer
+	// プロセスを殺すかストリームを閉じる
+	kill func() error
+
+	name    string
+	author  string
+	state   EngineState
+	usiOk   bool
+	readyOk bool
+	// Closeせずに終了したら再起動してSetOptionで設定した値を送り直す
+	autoRestart bool
+	// optionで宣言された順
+	options []*EngineOption
+	// SetOptionで設定した順の名前と値。再起動したら送り直す。
+	setOptions []setOption
+	// usiokとreadyokを受け取ったら送られる
+	usiOkC   chan struct{}
+	readyOkC chan struct{}
+	// SetPositionで送った局面と検証に使うルール
+	position *shogi.Position
+	rules    shogi.Rules
+	// プロセスを起動してからGoを送った回数と、それぞれの探索の局面
+	searches        int
+	searchPositions map[int]searchPosition
+	// readLinesが終わったら閉じる
+	done   chan struct{}
+	exit   *ExitStatus
+	closed bool
+
+	subMu       sync.Mutex
+	subscribers []*subscriber
+}
+
+type searchPosition struct {
+	position *shogi.Position
+	rules    shogi.Rules
+}
 
-	ReadyOk bool
-	USIOk   bool
+type setOption struct {
+	name  string
+	value string
 }
 
 func NewEngine(path string) (*Engine, error) {
-	cmd := exec.Command(path)
-	cmd.Dir = filepath.Dir(path)
+	return NewEngineFromConfig(EngineConfig{Path: path})
+}
+
+func NewEngineFromConfig(config EngineConfig) (*Engine, error) {
+	if config.Env == nil {
+		config.Env = os.Environ()
+	}
+	if config.Dir == "" {
+		config.Dir = filepath.Dir(config.Path)
+	}
+	engine := newEngine()
+	engine.config = &config
+	if err := engine.start(); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// rからエンジンの出力を読み、wにコマンドを書く。
+// SSHやソケットの先のエンジン、テスト用の偽のエンジンと話すのに使う。
+// rが終わったらエンジンが終了したものとする。Closeはrとwのうちio.Closerであるものを閉じる。
+// プロセスではないので再起動はできない。
+func NewEngineFromReadWriter(r io.Reader, w io.Writer) *Engine {
+	engine := newEngine()
+	kill := func() error {
+		var err error
+		for _, s := range []interface{}{w, r} {
+			if c, ok := s.(io.Closer); ok {
+				if cerr := c.Close(); err == nil {
+					err = cerr
+				}
+			}
+		}
+		return err
+	}
+	wait := func(readErr error) ExitStatus {
+		return ExitStatus{Err: readErr, ExitCode: -1}
+	}
+	engine.run(bufio.NewReader(r), w, kill, wait)
+	return engine
+}
+
+func newEngine() *Engine {
+	return &Engine{
+		state:    Initialized,
+		usiOkC:   make(chan struct{}, 1),
+		readyOkC: make(chan struct{}, 1),
+	}
+}
+
+// プロセスを起動してreadLinesを始める。
+func (e *Engine) start() error {
+	cmd := exec.Command(e.config.Path, e.config.Args...)
+	cmd.Dir = e.config.Dir
+	cmd.Env = e.config.Env
+	stderr := &tailBuffer{}
+	if e.config.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(stderr, e.config.Stderr)
+	} else {
+		cmd.Stderr = stderr
+	}
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if err := cmd.Start(); err != nil {
-		return nil, err
+		return err
 	}
 
-	engine := &Engine{
-		cmd:    cmd,
-		stdin:  bufio.NewWriter(stdin),
-		stdout: bufio.NewReader(stdout),
-		State:  Initialized,
+	wait := func(error) ExitStatus {
+		err := cmd.Wait()
+		return ExitStatus{Err: err, ExitCode: cmd.ProcessState.ExitCode(), Stderr: stderr.String()}
 	}
+	e.run(bufio.NewReader(stdout), stdin, cmd.Process.Kill, wait)
+	return nil
+}
 
-	go engine.readLines()
+// readLinesを始める。waitは読み終わった後に終了状態を返す。
+func (e *Engine) run(stdout *bufio.Reader, stdin io.Writer, kill func() error, wait func(readErr error) ExitStatus) {
+	done := make(chan struct{})
+	e.mu.Lock()
+	e.stdin = bufio.NewWriter(stdin)
+	e.kill = kill
+	e.done = done
+	e.exit = nil
+	e.closed = false
+	e.searches = 0
+	e.searchPositions = map[int]searchPosition{}
+	e.mu.Unlock()
+
+	go e.readLines(stdout, wait, done)
+}
 
-	return engine, nil
+// エンジンを終了させて終了状態を返す。
+// 思考中ならstopを送ってからquitを送って終了を待ち、ctxが終わっても終了しなければ殺す。
+// 既に終了していればその状態を返す。
+func (e *Engine) Close(ctx context.Context) (ExitStatus, error) {
+	e.mu.Lock()
+	e.closed = true
+	kill := e.kill
+	done := e.done
+	thinking := e.state == Thinking
+	e.mu.Unlock()
+
+	// エンジンが入力を読まないとSendが返らないことがあるので待たない
+	go func() {
+		if thinking {
+			e.SendStop()
+		}
+		e.Send("quit")
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if err := kill(); err != nil {
+			return ExitStatus{}, err
+		}
+		<-done
+	}
+	status, _ := e.Exited()
+	return status, nil
+}
+
+// エンジンが終了していればその状態を返す。
+func (e *Engine) Exited() (ExitStatus, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.exit == nil {
+		return ExitStatus{}, false
+	}
+	return *e.exit, true
+}
+
+// id nameで通知された名前。
+func (e *Engine) Name() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.name
+}
+
+func (e *Engine) Author() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.author
+}
+
+func (e *Engine) State() EngineState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.state
+}
+
+func (e *Engine) setState(state EngineState) {
+	e.mu.Lock()
+	e.state = state
+	e.mu.Unlock()
+}
+
+func (e *Engine) USIOk() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.usiOk
+}
+
+func (e *Engine) ReadyOk() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.readyOk
+}
+
+// Closeせずに終了したら再起動してSetOptionで設定した値を送り直すか。
+func (e *Engine) SetAutoRestart(autoRestart bool) {
+	e.mu.Lock()
+	e.autoRestart = autoRestart
+	e.mu.Unlock()
 }
 
-func (e *Engine) Close() error {
-	if err := e.cmd.Process.Kill(); err != nil {
+// エンジンを起動し直してusiokを待ち、SetOptionで設定した値を送り直してreadyokを待つ。
+// 動いているエンジンは終了させる。
+func (e *Engine) Restart(ctx context.Context) error {
+	if e.config == nil {
+		return errors.New("engine: cannot restart an engine without a process")
+	}
+	e.mu.Lock()
+	running := e.exit == nil
+	e.mu.Unlock()
+	if running {
+		if _, err := e.Close(ctx); err != nil {
+			return err
+		}
+	}
+
+	e.mu.Lock()
+	e.state = Initialized
+	e.usiOk = false
+	e.readyOk = false
+	e.options = nil
+	setOptions := e.setOptions
+	e.setOptions = nil
+	e.mu.Unlock()
+
+	if err := e.start(); err != nil {
 		return err
 	}
-	return nil
+	if err := e.Init(ctx); err != nil {
+		return err
+	}
+	for _, o := range setOptions {
+		if err := e.SetOption(o.name, o.value); err != nil {
+			return err
+		}
+	}
+	return e.WaitReady(ctx)
+}
+
+func (e *Engine) reportError(err error) {
+	e.emit(Event{Type: ErrorEvent, Err: err})
 }
 
-func (e *Engine) readLines() {
+// 再起動にかける時間
+const restartTimeout = 10 * time.Second
+
+// プロセスの出力を読む。終了したら終了状態を記録し、必要なら再起動する。
+func (e *Engine) readLines(stdout *bufio.Reader, wait func(readErr error) ExitStatus, done chan struct{}) {
+	var readErr error
+	// bestmoveかcheckmateで終わった探索の数。出力は次の探索のもの。
+	finished := 0
+	defer func() {
+		status := wait(readErr)
+		e.mu.Lock()
+		e.exit = &status
+		restart := e.autoRestart && !e.closed && e.config != nil
+		e.mu.Unlock()
+		close(done)
+
+		e.emit(Event{Type: ExitEvent, Exit: status})
+		if restart {
+			ctx, cancel := context.WithTimeout(context.Background(), restartTimeout)
+			defer cancel()
+			if err := e.Restart(ctx); err != nil {
+				e.reportError(fmt.Errorf("restart: %w", err))
+			}
+		}
+	}()
+
 	for {
-		line, err := e.stdout.ReadString('\n')
-		if err == io.EOF {
+		line, err := stdout.ReadString('\n')
+		if err != nil && err != io.EOF {
+			// 閉じた後の読み込みのエラーは報告しない
+			e.mu.Lock()
+			closed := e.closed
+			e.mu.Unlock()
+			if !closed {
+				readErr = err
+				e.reportError(err)
+			}
 			return
 		}
-		if err != nil {
-			panic(err)
+		if err == io.EOF && line == "" {
+			return
 		}
-		line = strings.Trim(line, "\n")
+		line = strings.TrimRight(line, "\r\n")
 
 		switch {
 		case line == "usiok":
-			e.State = Idling
-			e.USIOk = true
+			e.mu.Lock()
+			e.state = Idling
+			e.usiOk = true
+			e.mu.Unlock()
+			notify(e.usiOkC)
 		case line == "readyok":
-			e.State = Idling
-			e.ReadyOk = true
+			e.mu.Lock()
+			e.state = Idling
+			e.readyOk = true
+			e.mu.Unlock()
+			notify(e.readyOkC)
 		case strings.HasPrefix(line, "id name"):
-			e.Name = line[8:]
+			name := strings.TrimSpace(strings.TrimPrefix(line, "id name"))
+			e.mu.Lock()
+			e.name = name
+			e.mu.Unlock()
+			e.emit(Event{Type: IDEvent, Key: "name", Text: name})
 		case strings.HasPrefix(line, "id author"):
-			e.Author = line[10:]
+			author := strings.TrimSpace(strings.TrimPrefix(line, "id author"))
+			e.mu.Lock()
+			e.author = author
+			e.mu.Unlock()
+			e.emit(Event{Type: IDEvent, Key: "author", Text: author})
+		case strings.HasPrefix(line, "option"):
+			// 解釈できないoptionは無視する
+			if option, err := NewEngineOption(line); err == nil {
+				e.mu.Lock()
+				e.options = append(e.options, &option)
+				e.mu.Unlock()
+				e.emit(Event{Type: OptionEvent, Option: option})
+			}
 		case strings.HasPrefix(line, "info string"):
-			// 無視
+			e.emit(Event{Type: StringEvent, Text: strings.TrimPrefix(line, "info string "), Search: finished + 1})
 		case strings.HasPrefix(line, "info"):
-			if e.InfoC != nil {
-				info, err := NewUSIInfo(line)
-				if err != nil {
-					panic(err)
-				}
-				e.InfoC <- info
+			info, err := NewUSIInfo(line)
+			if err != nil {
+				e.reportError(err)
+				continue
+			}
+			e.emit(Event{Type: InfoEvent, Info: info, Search: finished + 1, search: e.searchPosition(finished + 1)})
+		case strings.HasPrefix(line, "checkmate"):
+			search := finished + 1
+			e.finishSearch(&finished)
+			checkmate, err := NewUSICheckmate(line)
+			if err != nil {
+				e.reportError(err)
+				continue
 			}
+			e.emit(Event{Type: CheckmateEvent, Checkmate: checkmate, Search: search})
 		case strings.HasPrefix(line, "bestmove"):
-			e.State = Idling
-			if e.BestMoveC != nil {
-				bestmove, err := NewUSIBestMove(line)
-				if err != nil {
-					panic(err)
-				}
-				e.BestMoveC <- bestmove
+			bestmove, err := NewUSIBestMove(line)
+			ev := Event{Type: BestMoveEvent, BestMove: bestmove, Search: finished + 1}
+			if err == nil {
+				e.validateBestMove(&ev)
+			}
+			e.finishSearch(&finished)
+			if err != nil {
+				e.reportError(err)
+				continue
 			}
+			e.emit(ev)
 		}
 	}
 }
 
-func (e *Engine) Send(command string) error {
-	_, err := e.stdin.WriteString(command + "\n")
-	if err != nil {
+// 今の出力の探索が終わった。Goを送っていなければ何もしない。
+// 後にGoを送った探索が残っていれば思考中のままにする。
+func (e *Engine) finishSearch(finished *int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if *finished < e.searches {
+		*finished++
+		delete(e.searchPositions, *finished)
+	}
+	if *finished == e.searches {
+		e.state = Idling
+	}
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// usiを送ってusiokを待つ。既にusiokを受け取っていれば何もしない。
+// ctxが終わるかエンジンが終了したらエラーを返す。
+func (e *Engine) Init(ctx context.Context) error {
+	e.mu.Lock()
+	usiOk := e.usiOk
+	e.mu.Unlock()
+	if usiOk {
+		return nil
+	}
+	if err := e.SendUSI(); err != nil {
 		return err
 	}
-	e.stdin.Flush()
+	if err := e.wait(ctx, e.usiOkC); err != nil {
+		return fmt.Errorf("usiok: %w", err)
+	}
+	return nil
+}
+
+// isreadyを送ってreadyokを待つ。
+// ctxが終わるかエンジンが終了したらエラーを返す。
+func (e *Engine) WaitReady(ctx context.Context) error {
+	// 前のreadyokは捨てる
+	select {
+	case <-e.readyOkC:
+	default:
+	}
+	if err := e.SendIsReady(); err != nil {
+		return err
+	}
+	if err := e.wait(ctx, e.readyOkC); err != nil {
+		return fmt.Errorf("readyok: %w", err)
+	}
 	return nil
 }
 
+func (e *Engine) wait(ctx context.Context, ch chan struct{}) error {
+	e.mu.Lock()
+	done := e.done
+	e.mu.Unlock()
+	select {
+	case <-ch:
+		return nil
+	case <-done:
+		return ErrExited
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Engine) Send(command string) error {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+	e.mu.Lock()
+	stdin := e.stdin
+	e.mu.Unlock()
+	if _, err := stdin.WriteString(command + "\n"); err != nil {
+		return err
+	}
+	return stdin.Flush()
+}
+
 func (e *Engine) SendUSI() error {
-	e.State = WaitingUSIOk
+	e.setState(WaitingUSIOk)
 	return e.Send("usi")
 }
 
 func (e *Engine) SendIsReady() error {
-	e.State = WaitingReadyOk
+	e.setState(WaitingReadyOk)
 	return e.Send("isready")
 }
 
 func (e *Engine) GoInfinite() error {
-	e.State = Thinking
-	return e.Send("go infinite")
+	_, err := e.Go(GoParams{Infinite: true})
+	return err
 }
 
 func (e *Engine) SendSFEN(sfen string, moves []string) error {
@@ -322,10 +714,74 @@ func (e *Engine) SendSFEN(sfen string, moves []string) error {
 	return e.Send(command)
 }
 
+// 宣言されているか確かめずにsetoptionを送る。valueが空ならbuttonとしてvalueを付けない。
 func (e *Engine) SendSetOption(name string, value string) error {
+	if value == "" {
+		return e.Send("setoption name " + name)
+	}
 	return e.Send(fmt.Sprintf("setoption name %s value %s", name, value))
 }
 
+// エンジンが宣言したオプション。
+func (e *Engine) Options() []EngineOption {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	options := []EngineOption{}
+	for _, o := range e.options {
+		options = append(options, *o)
+	}
+	return options
+}
+
+func (e *Engine) Option(name string) (EngineOption, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if o := e.option(name); o != nil {
+		return *o, true
+	}
+	return EngineOption{}, false
+}
+
+func (e *Engine) option(name string) *EngineOption {
+	for _, o := range e.options {
+		if o.Name == name {
+			return o
+		}
+	}
+	return nil
+}
+
+func (e *Engine) rememberOption(name, value string) {
+	for i, o := range e.setOptions {
+		if o.name == name {
+			e.setOptions[i].value = value
+			return
+		}
+	}
+	e.setOptions = append(e.setOptions, setOption{name, value})
+}
+
+// 宣言されたオプションの値を確かめてsetoptionを送る。buttonはvalueを空にする。
+func (e *Engine) SetOption(name string, value string) error {
+	e.mu.Lock()
+	o := e.option(name)
+	if o == nil {
+		e.mu.Unlock()
+		return fmt.Errorf("unknown option: %s", name)
+	}
+	if err := o.Validate(value); err != nil {
+		e.mu.Unlock()
+		return err
+	}
+	command := o.USI(value)
+	if o.Type != ButtonOption {
+		o.Value = value
+		e.rememberOption(name, value)
+	}
+	e.mu.Unlock()
+	return e.Send(command)
+}
+
 func (e *Engine) SendStop() error {
 	return e.Send("stop")
 }
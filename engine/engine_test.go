@@ -1,10 +1,104 @@
 package engine
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/eru1a/shogi-go"
 )
 
+// テストのバイナリを偽のUSIエンジンとして起動する
+const fakeEngineEnv = "SHOGI_ENGINE_FAKE_ENGINE"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(fakeEngineEnv); mode != "" {
+		runFakeEngine(mode)
+		os.Exit(0)
+	}
+	// -raceで作ったエンジンが終了の前に1秒待たないようにする
+	os.Setenv("GORACE", "atexit_sleep_ms=0")
+	os.Exit(m.Run())
+}
+
+// modeが"silent"なら何も返さず、"exit"ならすぐに終了する。
+// "crash"ならgo infiniteで壊れたinfoを出して異常終了する。
+// "args"ならコマンドライン引数を名前にし、標準エラー出力に書く。
+func runFakeEngine(mode string) {
+	if mode == "args" {
+		fmt.Fprintln(os.Stderr, "hello")
+	}
+	fakeEngine(mode, os.Stdin, os.Stdout)
+}
+
+func fakeEngine(mode string, r io.Reader, w io.Writer) {
+	if mode == "exit" {
+		return
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if mode == "silent" {
+			continue
+		}
+		switch scanner.Text() {
+		case "go mate 1000":
+			fmt.Fprintln(w, "checkmate timeout")
+		case "go mate infinite":
+			fmt.Fprintln(w, "checkmate G*5b 5a5b")
+		case "go infinite":
+			if mode == "crash" {
+				fmt.Fprintln(w, "info depth x")
+				fmt.Fprintln(os.Stderr, "boom")
+				os.Exit(3)
+			}
+		case "usi":
+			if mode == "args" {
+				fmt.Fprintln(w, "id name "+strings.Join(os.Args[1:], " "))
+			} else {
+				fmt.Fprintln(w, "id name fake")
+			}
+			fmt.Fprintln(w, "option name USI_Hash type spin default 256 min 1 max 4096")
+			fmt.Fprintln(w, "option name Clear Hash type button")
+			fmt.Fprintln(w, "usiok")
+		case "isready":
+			fmt.Fprintln(w, "readyok")
+		case "quit":
+			return
+		}
+	}
+}
+
+// quitで終了しなければ殺す。
+func closeEngine(t *testing.T, e *Engine) ExitStatus {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	status, err := e.Close(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	return status
+}
+
+func newFakeEngine(t *testing.T, mode string) *Engine {
+	t.Helper()
+	os.Setenv(fakeEngineEnv, mode)
+	defer os.Unsetenv(fakeEngineEnv)
+	e, err := NewEngine(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
 func TestNewUSIInfo(t *testing.T) {
 	tests := []struct {
 		line string
@@ -64,6 +158,32 @@ func TestNewUSIInfo(t *testing.T) {
 	}
 }
 
+func TestNewUSIInfoMalformed(t *testing.T) {
+	tests := []struct {
+		line string
+		info USIInfo
+		ok   bool
+	}{
+		{"info depth 3 score mate +", USIInfo{MultiPv: 1, Depth: 3, IsMate: true, ScoreMate: 1}, true},
+		{"info depth 3 score mate -", USIInfo{MultiPv: 1, Depth: 3, IsMate: true, ScoreMate: -1}, true},
+		{"info string mate depth", USIInfo{MultiPv: 1}, true},
+		{"info depth", USIInfo{}, false},
+		{"info depth 3 score cp", USIInfo{}, false},
+		{"info depth - score cp 10", USIInfo{}, false},
+		// 後のフィールドで最初のエラーを上書きしない
+		{"info depth x score cp 10", USIInfo{}, false},
+	}
+	for _, test := range tests {
+		info, err := NewUSIInfo(test.line)
+		if (err == nil) != test.ok {
+			t.Errorf("NewUSIInfo(%s): want ok %v, got %v", test.line, test.ok, err)
+		}
+		if !reflect.DeepEqual(info, test.info) {
+			t.Errorf("NewUSIInfo(%s):\n\twant\t%+v\n\tgot\t%+v", test.line, test.info, info)
+		}
+	}
+}
+
 func TestNewUSIBestMove(t *testing.T) {
 	tests := []struct {
 		line     string
@@ -94,3 +214,391 @@ func TestNewUSIBestMove(t *testing.T) {
 		}
 	}
 }
+
+func TestEngineInit(t *testing.T) {
+	tests := []struct {
+		mode string
+		want error
+	}{
+		{"ok", nil},
+		{"silent", context.DeadlineExceeded},
+		{"exit", ErrExited},
+	}
+
+	for _, test := range tests {
+		e := newFakeEngine(t, test.mode)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := e.Init(ctx)
+		if err == nil {
+			err = e.WaitReady(ctx)
+		}
+		e.Close(ctx)
+		cancel()
+
+		if test.want == nil {
+			if err != nil {
+				t.Errorf("%s: %v", test.mode, err)
+			}
+			continue
+		}
+		if err == nil || !errors.Is(err, test.want) {
+			t.Errorf("%s: want %v, got %v", test.mode, test.want, err)
+		}
+	}
+}
+
+func TestEngineOptions(t *testing.T) {
+	e := newFakeEngine(t, "ok")
+	defer closeEngine(t, e)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := e.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(e.Options()); got != 2 {
+		t.Fatalf("want 2 options, got %d", got)
+	}
+	if err := e.SetOption("USI_Hash", "1024"); err != nil {
+		t.Fatal(err)
+	}
+	if o, _ := e.Option("USI_Hash"); o.Value != "1024" {
+		t.Errorf("want 1024, got %s", o.Value)
+	}
+	if err := e.SetOption("USI_Hash", "0"); err == nil {
+		t.Errorf("want range error")
+	}
+	if err := e.SetOption("Clear Hash", ""); err != nil {
+		t.Error(err)
+	}
+	if err := e.SetOption("Unknown", "1"); err == nil {
+		t.Errorf("want unknown option error")
+	}
+}
+
+func TestEngineGoMate(t *testing.T) {
+	e := newFakeEngine(t, "ok")
+	defer closeEngine(t, e)
+	events, unsubscribe := e.Subscribe()
+	defer unsubscribe()
+
+	tests := []struct {
+		timeout time.Duration
+		want    USICheckmate
+	}{
+		{time.Second, USICheckmate{Status: CheckmateTimeout}},
+		{0, USICheckmate{Status: CheckmateFound, Moves: []string{"G*5b", "5a5b"}}},
+	}
+	for _, test := range tests {
+		if err := e.GoMate(test.timeout); err != nil {
+			t.Fatal(err)
+		}
+		ev := waitEvent(t, events, CheckmateEvent)
+		if !reflect.DeepEqual(ev.Checkmate, test.want) {
+			t.Errorf("want %+v, got %+v", test.want, ev.Checkmate)
+		}
+	}
+}
+
+func TestNewUSICheckmate(t *testing.T) {
+	tests := []struct {
+		line string
+		want USICheckmate
+	}{
+		{"checkmate nomate", USICheckmate{Status: CheckmateNoMate}},
+		{"checkmate notimplemented", USICheckmate{Status: CheckmateNotImplemented}},
+		{"checkmate R*1a", USICheckmate{Status: CheckmateFound, Moves: []string{"R*1a"}}},
+	}
+	for _, test := range tests {
+		got, err := NewUSICheckmate(test.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("NewUSICheckmate(%s): want %+v, got %+v", test.line, test.want, got)
+		}
+	}
+	if _, err := NewUSICheckmate("checkmate"); err == nil {
+		t.Errorf("want error")
+	}
+}
+
+func TestEngineCrash(t *testing.T) {
+	e := newFakeEngine(t, "crash")
+	defer closeEngine(t, e)
+	events, unsubscribe := e.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := e.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetOption("USI_Hash", "1024"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.GoInfinite(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 壊れたinfoのエラーが終了より先に届く
+	waitEvent(t, events, ErrorEvent)
+	status := waitEvent(t, events, ExitEvent).Exit
+	if status.ExitCode != 3 || !strings.Contains(status.Stderr, "boom") {
+		t.Errorf("exit: got %+v", status)
+	}
+	if _, exited := e.Exited(); !exited {
+		t.Errorf("want exited")
+	}
+
+	// 再起動したら設定したオプションを送り直す
+	if err := e.Restart(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if o, _ := e.Option("USI_Hash"); o.Value != "1024" {
+		t.Errorf("want 1024, got %s", o.Value)
+	}
+	if _, exited := e.Exited(); exited {
+		t.Errorf("want running after restart")
+	}
+}
+
+func TestEngineAutoRestart(t *testing.T) {
+	e := newFakeEngine(t, "crash")
+	defer closeEngine(t, e)
+	e.SetAutoRestart(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := e.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.GoInfinite(); err != nil {
+		t.Fatal(err)
+	}
+	// 再起動が終わるまで待つ
+	for {
+		if err := e.WaitReady(ctx); err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatal("restart timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// typeのイベントが届くまで待つ。他のイベントは捨てる。
+func waitEvent(t *testing.T, events <-chan Event, typ EventType) Event {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatalf("%v: closed", typ)
+			}
+			if ev.Type == typ {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("%v: timeout", typ)
+		}
+	}
+}
+
+func TestEngineSubscribe(t *testing.T) {
+	e := newFakeEngine(t, "ok")
+	defer closeEngine(t, e)
+	events, unsubscribe := e.Subscribe()
+	other, unsubscribeOther := e.Subscribe()
+	defer unsubscribeOther()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := e.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if e.Name() != "fake" || !e.USIOk() || e.State() != Idling {
+		t.Errorf("got name %q, usiok %v, state %v", e.Name(), e.USIOk(), e.State())
+	}
+
+	// 出力された順に届き、登録した全員が受け取る
+	for _, ch := range []<-chan Event{events, other} {
+		want := []string{"id fake", "option USI_Hash", "option Clear Hash"}
+		for _, w := range want {
+			ev := <-ch
+			got := ev.Type.String() + " " + ev.Text + ev.Option.Name
+			if got != w {
+				t.Errorf("want %q, got %q", w, got)
+			}
+		}
+	}
+
+	// 解除したら閉じられ、二度呼んでもよい
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Errorf("want closed")
+	}
+	if err := e.GoMate(0); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, other, CheckmateEvent)
+}
+
+func TestNewEngineFromConfig(t *testing.T) {
+	stderr := &strings.Builder{}
+	e, err := NewEngineFromConfig(EngineConfig{
+		Path:   os.Args[0],
+		Args:   []string{"a", "b"},
+		Env:    append(os.Environ(), fakeEngineEnv+"=args"),
+		Stderr: stderr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, unsubscribe := e.Subscribe()
+	defer unsubscribe()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := e.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if e.Name() != "a b" {
+		t.Errorf("want name %q, got %q", "a b", e.Name())
+	}
+	e.Send("quit")
+	waitEvent(t, events, ExitEvent)
+	if stderr.String() != "hello\n" {
+		t.Errorf("stderr: got %q", stderr.String())
+	}
+}
+
+func TestNewEngineFromReadWriter(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	go func() {
+		fakeEngine("ok", stdinR, stdoutW)
+		stdoutW.Close()
+	}()
+
+	e := NewEngineFromReadWriter(stdoutR, stdinW)
+	events, unsubscribe := e.Subscribe()
+	defer unsubscribe()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := e.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if e.Name() != "fake" || len(e.Options()) != 2 {
+		t.Errorf("got name %q, options %v", e.Name(), e.Options())
+	}
+	if err := e.Restart(ctx); err == nil {
+		t.Errorf("want restart error")
+	}
+
+	// 出力が終わったら終了したものとする
+	e.Send("quit")
+	if status := waitEvent(t, events, ExitEvent).Exit; status.ExitCode != -1 || status.Err != nil {
+		t.Errorf("exit: got %+v", status)
+	}
+	if _, err := e.Close(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEngineClose(t *testing.T) {
+	// quitで終了する
+	e := newFakeEngine(t, "ok")
+	if status := closeEngine(t, e); status.Err != nil || status.ExitCode != 0 {
+		t.Errorf("ok: got %+v", status)
+	}
+
+	// quitを無視するので殺す
+	e = newFakeEngine(t, "silent")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	status, err := e.Close(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Err == nil {
+		t.Errorf("silent: want killed, got %+v", status)
+	}
+
+	// 終了した後に呼んでも同じ状態を返す
+	if again := closeEngine(t, e); again.ExitCode != status.ExitCode {
+		t.Errorf("want %+v, got %+v", status, again)
+	}
+}
+
+func TestEngineSendBlocked(t *testing.T) {
+	// stdinを読まないエンジン
+	_, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	e := NewEngineFromReadWriter(stdoutR, stdinW)
+	defer closeEngine(t, e)
+
+	go e.Send("usi")
+	// 書き込みが返らなくても出力を読んで状態を見られる
+	done := make(chan struct{})
+	go func() {
+		fmt.Fprintln(stdoutW, "id name fake")
+		fmt.Fprintln(stdoutW, "usiok")
+		for !e.USIOk() {
+			time.Sleep(time.Millisecond)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("deadlock")
+	}
+	if e.Name() != "fake" {
+		t.Errorf("got name %q", e.Name())
+	}
+}
+
+func TestEngineAnalyzeStopTimeout(t *testing.T) {
+	defer func(d time.Duration) { stopTimeout = d }(stopTimeout)
+	stopTimeout = 50 * time.Millisecond
+
+	// stopを受け取ってもbestmoveを返さないエンジン
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	go io.Copy(ioutil.Discard, stdinR)
+	e := NewEngineFromReadWriter(stdoutR, stdinW)
+	defer closeEngine(t, e)
+	defer stdoutW.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := e.Analyze(ctx, shogi.NewPosition(), GoParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	var last Analysis
+	timer := time.NewTimer(time.Second)
+	defer timer.Stop()
+	for done := false; !done; {
+		select {
+		case a, ok := <-c:
+			if !ok {
+				done = true
+				break
+			}
+			last = a
+		case <-timer.C:
+			t.Fatal("analysis did not end")
+		}
+	}
+	if !errors.Is(last.Err, ErrNoBestMove) || last.Done {
+		t.Errorf("want %v, got %+v", ErrNoBestMove, last)
+	}
+}
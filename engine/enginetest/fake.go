@@ -0,0 +1,262 @@
+// enginetestはengine.Engineを使うもののテストのための偽のUSIエンジンを提供する。
+package enginetest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eru1a/shogi-go/engine"
+)
+
+// 偽のエンジンが出力する1行。
+type Line struct {
+	Text string
+	// 出力する前に待つ時間
+	Delay time.Duration
+	// 出力した後に終了する。Textが空なら何も出力せずに終了する。
+	Exit bool
+}
+
+// 台本どおりに応答する偽のUSIエンジン。
+// usiにはidとoptionとusiokを、isreadyにはreadyokを返し、受け取ったコマンドを全て記録する。
+type Fake struct {
+	Name   string
+	Author string
+	// "option name USI_Hash type spin default 256 min 1 max 4096"のような行
+	Options []string
+	// usiokとreadyokを返す前に待つ時間
+	Delay time.Duration
+	// goを受け取ったら出力する行。positionは最後に受け取ったpositionコマンド。
+	// nilならbestmove resignを返す。bestmoveを含まなければstopを受け取ってからStopBestMoveを返す。
+	OnGo func(position, command string) []Line
+	// 空なら"bestmove resign"
+	StopBestMove string
+	// stopを無視してbestmoveを返さない
+	IgnoreStop bool
+
+	mu     sync.Mutex
+	r      *io.PipeReader
+	w      *io.PipeWriter
+	exited bool
+	// 終了したら閉じる
+	quit     chan struct{}
+	commands []string
+	// コマンドを受け取るたびに閉じて作り直す
+	received chan struct{}
+	// 思考中ならstopで閉じる
+	stop chan struct{}
+	// 思考を終えたら閉じる
+	thinking chan struct{}
+}
+
+// 同じ行を返すOnGo。
+func Reply(lines ...string) func(position, command string) []Line {
+	return func(position, command string) []Line {
+		ls := []Line{}
+		for _, l := range lines {
+			ls = append(ls, Line{Text: l})
+		}
+		return ls
+	}
+}
+
+// 偽のエンジンを動かし、それと話すEngineを返す。
+func (f *Fake) Engine() *engine.Engine {
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	f.mu.Lock()
+	f.r = stdinR
+	f.w = stdoutW
+	f.exited = false
+	f.quit = make(chan struct{})
+	if f.received == nil {
+		f.received = make(chan struct{})
+	}
+	f.mu.Unlock()
+	go f.run(stdinR, f.quit)
+	return engine.NewEngineFromReadWriter(stdoutR, stdinW)
+}
+
+// 受け取ったコマンド。
+func (f *Fake) Commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.commands...)
+}
+
+// prefixで始まるコマンドを受け取るまで待ち、そのコマンドを返す。既に受け取っていればすぐに返す。
+func (f *Fake) WaitCommand(ctx context.Context, prefix string) (string, error) {
+	for {
+		f.mu.Lock()
+		for _, c := range f.commands {
+			if strings.HasPrefix(c, prefix) {
+				f.mu.Unlock()
+				return c, nil
+			}
+		}
+		received := f.received
+		f.mu.Unlock()
+
+		select {
+		case <-received:
+		case <-ctx.Done():
+			return "", fmt.Errorf("enginetest: waiting %q: %w", prefix, ctx.Err())
+		}
+	}
+}
+
+func (f *Fake) run(r *io.PipeReader, quit chan struct{}) {
+	defer f.exit()
+	position := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		command := scanner.Text()
+		f.record(command)
+
+		switch {
+		case command == "usi":
+			lines := []Line{}
+			if f.Name != "" {
+				lines = append(lines, Line{Text: "id name " + f.Name})
+			}
+			if f.Author != "" {
+				lines = append(lines, Line{Text: "id author " + f.Author})
+			}
+			for _, o := range f.Options {
+				lines = append(lines, Line{Text: o})
+			}
+			lines = append(lines, Line{Text: "usiok", Delay: f.Delay})
+			f.output(lines, nil, quit)
+		case command == "isready":
+			f.output([]Line{{Text: "readyok", Delay: f.Delay}}, nil, quit)
+		case strings.HasPrefix(command, "position"):
+			position = command
+		case strings.HasPrefix(command, "go"):
+			stop := make(chan struct{})
+			thinking := make(chan struct{})
+			f.mu.Lock()
+			f.stop = stop
+			f.thinking = thinking
+			f.mu.Unlock()
+			go func() {
+				defer close(thinking)
+				f.think(position, command, stop, quit)
+			}()
+		case command == "stop" && f.IgnoreStop:
+		case command == "stop":
+			// 本物のエンジンと同じくbestmoveを出すまで次のコマンドを読まない
+			f.mu.Lock()
+			stop, thinking := f.stop, f.thinking
+			f.stop = nil
+			f.mu.Unlock()
+			if stop != nil {
+				close(stop)
+				select {
+				case <-thinking:
+				case <-quit:
+				}
+			}
+		case command == "quit":
+			f.exit()
+			return
+		}
+		if f.isExited() {
+			return
+		}
+	}
+}
+
+func (f *Fake) think(position, command string, stop, quit chan struct{}) {
+	lines := []Line{{Text: "bestmove resign"}}
+	if f.OnGo != nil {
+		lines = f.OnGo(position, command)
+	}
+	if f.output(lines, stop, quit) {
+		return
+	}
+	for _, l := range lines {
+		if strings.HasPrefix(l.Text, "bestmove") {
+			return
+		}
+	}
+	select {
+	case <-stop:
+	case <-quit:
+		return
+	}
+	bestMove := f.StopBestMove
+	if bestMove == "" {
+		bestMove = "bestmove resign"
+	}
+	f.output([]Line{{Text: bestMove}}, nil, quit)
+}
+
+// linesを出力する。stopが閉じられたら待たずにbestmoveまで進み、infoは捨てる。
+// 終了したらtrueを返す。
+func (f *Fake) output(lines []Line, stop, quit chan struct{}) bool {
+	stopped := false
+	for _, l := range lines {
+		if !stopped && l.Delay > 0 {
+			timer := time.NewTimer(l.Delay)
+			select {
+			case <-timer.C:
+			case <-stop:
+				timer.Stop()
+				stopped = true
+			case <-quit:
+				timer.Stop()
+				return true
+			}
+		}
+		if stopped && strings.HasPrefix(l.Text, "info") {
+			continue
+		}
+
+		f.mu.Lock()
+		if f.exited {
+			f.mu.Unlock()
+			return true
+		}
+		w := f.w
+		f.mu.Unlock()
+		if l.Text != "" {
+			fmt.Fprintln(w, l.Text)
+		}
+		if l.Exit {
+			f.exit()
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Fake) record(command string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, command)
+	close(f.received)
+	f.received = make(chan struct{})
+}
+
+// 入出力を閉じる。Engineは終了したものとし、以後のコマンドの送信は失敗する。
+func (f *Fake) exit() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.exited {
+		f.exited = true
+		close(f.quit)
+		f.w.Close()
+		f.r.Close()
+	}
+}
+
+func (f *Fake) isExited() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.exited
+}
@@ -0,0 +1,181 @@
+package enginetest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eru1a/shogi-go/engine"
+)
+
+func waitEvent(t *testing.T, events <-chan engine.Event, typ engine.EventType) engine.Event {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == typ {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("%v: timeout", typ)
+		}
+	}
+}
+
+func TestFake(t *testing.T) {
+	f := &Fake{
+		Name:    "fake",
+		Options: []string{"option name USI_Hash type spin default 256 min 1 max 4096"},
+		OnGo: func(position, command string) []Line {
+			return []Line{
+				{Text: "info depth 1 score cp 10 pv 7g7f", Delay: 10 * time.Millisecond},
+				{Text: "info depth x"},
+				{Text: "bestmove 7g7f"},
+			}
+		},
+	}
+	e := f.Engine()
+	defer e.Close(context.Background())
+	events, unsubscribe := e.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := e.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if e.Name() != "fake" || len(e.Options()) != 1 {
+		t.Errorf("got name %q, options %v", e.Name(), e.Options())
+	}
+
+	e.Send("position startpos")
+	e.Go(engine.GoParams{Depth: 1})
+	if info := waitEvent(t, events, engine.InfoEvent).Info; info.ScoreCp != 10 {
+		t.Errorf("info: got %+v", info)
+	}
+	waitEvent(t, events, engine.ErrorEvent)
+	if bestMove := waitEvent(t, events, engine.BestMoveEvent).BestMove; bestMove.BestMove != "7g7f" {
+		t.Errorf("bestmove: got %+v", bestMove)
+	}
+
+	want := []string{"usi", "isready", "position startpos", "go depth 1"}
+	if got := f.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands: want %v, got %v", want, got)
+	}
+}
+
+func TestFakeStop(t *testing.T) {
+	f := &Fake{
+		OnGo: func(position, command string) []Line {
+			return []Line{{Text: "info depth 30 score cp 0", Delay: time.Hour}}
+		},
+		StopBestMove: "bestmove 2g2f",
+	}
+	e := f.Engine()
+	defer e.Close(context.Background())
+	events, unsubscribe := e.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	e.GoInfinite()
+	if _, err := f.WaitCommand(ctx, "go infinite"); err != nil {
+		t.Fatal(err)
+	}
+	e.SendStop()
+	// 待っていたinfoは出さずにbestmoveを返す
+	ev := <-events
+	if ev.Type != engine.BestMoveEvent || ev.BestMove.BestMove != "2g2f" {
+		t.Errorf("want bestmove 2g2f, got %+v", ev)
+	}
+}
+
+func TestFakeExit(t *testing.T) {
+	f := &Fake{
+		OnGo: func(position, command string) []Line {
+			return []Line{{Text: "info depth 1"}, {Exit: true}}
+		},
+	}
+	e := f.Engine()
+	defer e.Close(context.Background())
+	events, unsubscribe := e.Subscribe()
+	defer unsubscribe()
+
+	e.GoInfinite()
+	waitEvent(t, events, engine.ExitEvent)
+	if _, exited := e.Exited(); !exited {
+		t.Errorf("want exited")
+	}
+	if err := e.Send("isready"); err == nil {
+		t.Errorf("want send error")
+	}
+}
+
+func TestFakeClose(t *testing.T) {
+	f := &Fake{
+		OnGo: func(position, command string) []Line {
+			return []Line{{Text: "info depth 1", Delay: time.Hour}}
+		},
+	}
+	e := f.Engine()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	e.GoInfinite()
+	if _, err := f.WaitCommand(ctx, "go infinite"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 思考中ならstopしてからquitを送る
+	if _, err := e.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"go infinite", "stop", "quit"}
+	if got := f.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestFakeMalformed(t *testing.T) {
+	f := &Fake{
+		OnGo: func(position, command string) []Line {
+			return []Line{
+				{Text: "id name"},
+				{Text: "id author"},
+				{Text: "info depth"},
+				{Text: "info depth 3 score mate +"},
+				{Text: "info depth x score cp 10"},
+				{Text: "bestmove resign"},
+			}
+		},
+	}
+	e := f.Engine()
+	defer e.Close(context.Background())
+	events, unsubscribe := e.Subscribe()
+	defer unsubscribe()
+
+	// 壊れた出力で読み込みが止まらず、エラーとして届く
+	e.GoInfinite()
+	errors := 0
+	for ev := range events {
+		if ev.Type == engine.ErrorEvent {
+			errors++
+		}
+		if ev.Type == engine.InfoEvent && (!ev.Info.IsMate || ev.Info.ScoreMate != 1) {
+			t.Errorf("info: got %+v", ev.Info)
+		}
+		if ev.Type == engine.BestMoveEvent {
+			break
+		}
+	}
+	if errors != 2 {
+		t.Errorf("want 2 errors, got %d", errors)
+	}
+	if e.Name() != "" || e.Author() != "" {
+		t.Errorf("got name %q, author %q", e.Name(), e.Author())
+	}
+}
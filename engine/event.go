@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"sync"
+
+	"github.com/eru1a/shogi-go"
+)
+
+type EventType uint8
+
+const (
+	// id nameとid author
+	IDEvent EventType = iota
+	OptionEvent
+	InfoEvent
+	// info string
+	StringEvent
+	BestMoveEvent
+	CheckmateEvent
+	// 読み込みや解釈のエラー
+	ErrorEvent
+	// エンジンの終了。再起動すれば続けてイベントが届く。
+	ExitEvent
+)
+
+func (t EventType) String() string {
+	switch t {
+	case IDEvent:
+		return "id"
+	case OptionEvent:
+		return "option"
+	case InfoEvent:
+		return "info"
+	case StringEvent:
+		return "string"
+	case BestMoveEvent:
+		return "bestmove"
+	case CheckmateEvent:
+		return "checkmate"
+	case ErrorEvent:
+		return "error"
+	case ExitEvent:
+		return "exit"
+	}
+	return ""
+}
+
+// エンジンから届いたもの。Typeに対応するフィールドだけが設定される。
+type Event struct {
+	Type EventType
+	// idの"name"か"author"
+	Key string
+	// idの値かinfo stringの文字列
+	Text      string
+	Option    EngineOption
+	Info      USIInfo
+	BestMove  USIBestMove
+	Checkmate USICheckmate
+	Err       error
+	Exit      ExitStatus
+	// info、bestmove、checkmateがどの探索への出力か。Searchの番号。
+	Search int
+
+	// SetPositionで局面を送っていれば、bestmoveとponderを検証した指し手
+	Move   shogi.Move
+	Ponder shogi.Move
+	// bestmoveが指せない手だった
+	Illegal bool
+
+	// 探索した局面。pvはPvで必要になってから検証する。
+	search *searchPosition
+}
+
+// Subscribeで登録した受け取り手。
+// 受け取り手が読まなくてもエンジンの出力の読み込みを止めないように、届いたイベントは溜めておく。
+type subscriber struct {
+	mu    sync.Mutex
+	queue []Event
+	wake  chan struct{}
+	quit  chan struct{}
+	once  sync.Once
+	c     chan Event
+}
+
+func newSubscriber() *subscriber {
+	s := &subscriber{
+		wake: make(chan struct{}, 1),
+		quit: make(chan struct{}),
+		c:    make(chan Event),
+	}
+	go s.run()
+	return s
+}
+
+func (s *subscriber) push(ev Event) {
+	s.mu.Lock()
+	s.queue = append(s.queue, ev)
+	s.mu.Unlock()
+	notify(s.wake)
+}
+
+func (s *subscriber) stop() {
+	s.once.Do(func() { close(s.quit) })
+}
+
+// 溜まったイベントを順に送る。stopしたらcを閉じる。
+func (s *subscriber) run() {
+	defer close(s.c)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.quit:
+				return
+			}
+		}
+		ev := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		select {
+		case s.c <- ev:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// 登録した後にエンジンから届いたイベントを順に受け取るチャンネルと、登録を解除する関数を返す。
+// 解除するとチャンネルは閉じられる。解除は何度呼んでもよい。
+func (e *Engine) Subscribe() (<-chan Event, func()) {
+	s := newSubscriber()
+	e.subMu.Lock()
+	e.subscribers = append(e.subscribers, s)
+	e.subMu.Unlock()
+
+	unsubscribe := func() {
+		e.subMu.Lock()
+		for i, sub := range e.subscribers {
+			if sub == s {
+				e.subscribers = append(e.subscribers[:i], e.subscribers[i+1:]...)
+				break
+			}
+		}
+		e.subMu.Unlock()
+		s.stop()
+	}
+	return s.c, unsubscribe
+}
+
+func (e *Engine) emit(ev Event) {
+	e.subMu.Lock()
+	defer e.subMu.Unlock()
+	for _, s := range e.subscribers {
+		s.push(ev)
+	}
+}
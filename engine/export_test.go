@@ -0,0 +1,10 @@
+package engine
+
+import "time"
+
+// stopを送ってからbestmoveを待つ時間を変え、元に戻す関数を返す。
+func SetStopTimeout(d time.Duration) func() {
+	old := stopTimeout
+	stopTimeout = d
+	return func() { stopTimeout = old }
+}
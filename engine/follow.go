@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/eru1a/shogi-go"
+)
+
+// go infiniteで解析を続けながら解析する局面を切り替える。
+// 局面を切り替えたら前の局面の出力は捨て、今の局面の途中経過だけをCに送る。
+type Follower struct {
+	// 局面を切り替えると、まずPVsが空の途中経過を送る。
+	// 受け取り手が遅れたら最新のものだけを送る。Closeしたら閉じる。
+	C <-chan Analysis
+
+	engine *Engine
+	c      chan Analysis
+
+	mu       sync.Mutex
+	position *shogi.Position
+	// 今の局面の探索の番号
+	search int
+	closed bool
+	// 局面を切り替えたら送られる
+	changed chan struct{}
+
+	events      <-chan Event
+	unsubscribe func()
+}
+
+func NewFollower(e *Engine) *Follower {
+	events, unsubscribe := e.Subscribe()
+	c := make(chan Analysis)
+	f := &Follower{
+		C:           c,
+		engine:      e,
+		c:           c,
+		changed:     make(chan struct{}, 1),
+		events:      events,
+		unsubscribe: unsubscribe,
+	}
+	go f.run()
+	return f
+}
+
+// pからmovesを指した局面の解析を始める。思考中ならstopを送ってから局面を送り直す。
+func (f *Follower) SetPosition(p *shogi.Position, moves []shogi.Move) error {
+	return f.follow(func() error { return f.engine.SetPosition(p, moves) })
+}
+
+// treeのCurrentの局面の解析を始める。思考中ならstopを送ってから局面を送り直す。
+func (f *Follower) SetGameTree(tree *shogi.GameTree) error {
+	return f.follow(func() error { return f.engine.SetGameTree(tree) })
+}
+
+func (f *Follower) follow(setPosition func() error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return errors.New("engine: follower closed")
+	}
+
+	if f.engine.State() == Thinking {
+		if err := f.engine.SendStop(); err != nil {
+			return err
+		}
+	}
+	if err := setPosition(); err != nil {
+		return err
+	}
+	search, err := f.engine.Go(GoParams{Infinite: true})
+	if err != nil {
+		return err
+	}
+	f.position = f.engine.Position()
+	f.search = search
+	notify(f.changed)
+	return nil
+}
+
+// 思考中ならstopを送って解析をやめ、Cを閉じる。
+func (f *Follower) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	f.mu.Unlock()
+
+	var err error
+	if f.engine.State() == Thinking {
+		err = f.engine.SendStop()
+	}
+	f.unsubscribe()
+	return err
+}
+
+func (f *Follower) current() (*shogi.Position, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.position, f.search
+}
+
+func (f *Follower) run() {
+	defer close(f.c)
+	pvs := map[int]PV{}
+	position, search := f.current()
+	var pending *Analysis
+	// 局面が切り替わっていたら途中経過を空にする
+	refresh := func() {
+		if p, s := f.current(); s != search {
+			position, search = p, s
+			pvs = map[int]PV{}
+			pending = snapshot(position, pvs)
+		}
+	}
+
+	for {
+		var out chan<- Analysis
+		var next Analysis
+		if pending != nil {
+			out = f.c
+			next = *pending
+		}
+
+		select {
+		case ev, ok := <-f.events:
+			if !ok {
+				return
+			}
+			// 切り替えの通知より先に新しい局面の出力が届くことがある
+			refresh()
+			switch {
+			case ev.Type == ExitEvent:
+				a := snapshot(position, pvs)
+				a.Err = ErrExited
+				pending = a
+			case ev.Search != search || position == nil:
+				// 前の局面の出力は捨てる
+			case ev.Type == InfoEvent && (ev.Info.IsCp || ev.Info.IsMate):
+				pvs[ev.Info.MultiPv] = newPV(ev, position.Turn)
+				pending = snapshot(position, pvs)
+			case ev.Type == BestMoveEvent:
+				a := snapshot(position, pvs)
+				a.Done = true
+				a.BestMove = ev.Move
+				a.Ponder = ev.Ponder
+				pending = a
+			}
+		case <-f.changed:
+			refresh()
+		case out <- next:
+			pending = nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package engine_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eru1a/shogi-go"
+	"github.com/eru1a/shogi-go/engine"
+	"github.com/eru1a/shogi-go/engine/enginetest"
+)
+
+func TestFollower(t *testing.T) {
+	f := &enginetest.Fake{
+		OnGo: func(position, command string) []enginetest.Line {
+			if strings.HasSuffix(position, "startpos") {
+				return []enginetest.Line{{Text: "info depth 1 score cp 100 pv 7g7f"}}
+			}
+			return []enginetest.Line{{Text: "info depth 1 score cp -50 pv 3c3d"}}
+		},
+		// 前の局面の指し手なので新しい局面では指せない
+		StopBestMove: "bestmove 2g2f",
+	}
+	e := f.Engine()
+	defer e.Close(context.Background())
+	follower := engine.NewFollower(e)
+
+	// wantの評価値が届くまで読む
+	wait := func(want int) {
+		t.Helper()
+		timeout := time.After(2 * time.Second)
+		for {
+			select {
+			case a := <-follower.C:
+				if a.Done {
+					t.Fatalf("stale bestmove: %+v", a)
+				}
+				if len(a.PVs) == 0 {
+					continue
+				}
+				// 局面と評価値が食い違わない
+				if turn := a.Position.Turn; turn == shogi.Black && a.PVs[0].Score != 100 ||
+					turn == shogi.White && a.PVs[0].Score != 50 {
+					t.Fatalf("got %v for %v", a.PVs[0].Score, turn)
+				}
+				if a.PVs[0].Score == want {
+					return
+				}
+			case <-timeout:
+				t.Fatal("timeout")
+			}
+		}
+	}
+
+	if err := follower.SetPosition(shogi.NewPosition(), nil); err != nil {
+		t.Fatal(err)
+	}
+	wait(100)
+	if err := follower.SetPosition(shogi.NewPosition(), usiMoves(t, "7g7f")); err != nil {
+		t.Fatal(err)
+	}
+	wait(50)
+
+	if err := follower.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for range follower.C {
+	}
+	// 思考中なのでstopを送る
+	want := []string{"position startpos", "go infinite", "stop", "position startpos moves 7g7f", "go infinite", "stop"}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := f.WaitCommand(ctx, "position startpos moves"); err != nil {
+		t.Fatal(err)
+	}
+	for len(f.Commands()) < len(want) && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := f.Commands(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// goコマンドの引数。ゼロの値は送らない。
+type GoParams struct {
+	BTime   time.Duration
+	WTime   time.Duration
+	Byoyomi time.Duration
+	BInc    time.Duration
+	WInc    time.Duration
+	// 1手に使う時間(USIの拡張)
+	MoveTime time.Duration
+	Depth    int
+	Nodes    int
+	// 詰み探索(go mate)の制限時間。MateInfiniteなら時間無制限。
+	// どちらかを指定すると他の引数は送らない。
+	Mate         time.Duration
+	MateInfinite bool
+	Ponder       bool
+	Infinite     bool
+	// 探索する指し手をこれに限る
+	SearchMoves []string
+}
+
+// 制限も指定もなく、"go"だけを送るならtrue。
+func (p GoParams) IsZero() bool {
+	return p.BTime == 0 && p.WTime == 0 && p.Byoyomi == 0 && p.BInc == 0 && p.WInc == 0 &&
+		p.MoveTime == 0 && p.Depth == 0 && p.Nodes == 0 && p.Mate == 0 && !p.MateInfinite &&
+		!p.Ponder && !p.Infinite && len(p.SearchMoves) == 0
+}
+
+func (p GoParams) USI() string {
+	ms := func(d time.Duration) int64 {
+		return int64(d / time.Millisecond)
+	}
+
+	if p.MateInfinite {
+		return "go mate infinite"
+	}
+	if p.Mate > 0 {
+		return fmt.Sprintf("go mate %d", ms(p.Mate))
+	}
+
+	s := []string{"go"}
+	if p.Ponder {
+		s = append(s, "ponder")
+	}
+	// 持ち時間を使うなら両者の残り時間を必ず送る
+	if p.BTime > 0 || p.WTime > 0 || p.Byoyomi > 0 || p.BInc > 0 || p.WInc > 0 {
+		s = append(s, fmt.Sprintf("btime %d wtime %d", ms(p.BTime), ms(p.WTime)))
+		if p.BInc > 0 || p.WInc > 0 {
+			s = append(s, fmt.Sprintf("binc %d winc %d", ms(p.BInc), ms(p.WInc)))
+		} else {
+			s = append(s, fmt.Sprintf("byoyomi %d", ms(p.Byoyomi)))
+		}
+	}
+	if p.MoveTime > 0 {
+		s = append(s, fmt.Sprintf("movetime %d", ms(p.MoveTime)))
+	}
+	if p.Depth > 0 {
+		s = append(s, fmt.Sprintf("depth %d", p.Depth))
+	}
+	if p.Nodes > 0 {
+		s = append(s, fmt.Sprintf("nodes %d", p.Nodes))
+	}
+	if p.Infinite {
+		s = append(s, "infinite")
+	}
+	// 指し手が行末まで続くので最後に置く
+	if len(p.SearchMoves) > 0 {
+		s = append(s, "searchmoves "+strings.Join(p.SearchMoves, " "))
+	}
+	return strings.Join(s, " ")
+}
+
+// 探索を始め、その番号を返す。出力は探索の番号を付けて届き、bestmoveの検証にはこの時点の局面を使う。
+func (e *Engine) Go(p GoParams) (int, error) {
+	e.mu.Lock()
+	e.state = Thinking
+	e.searches++
+	search := e.searches
+	e.searchPositions[search] = searchPosition{e.position, e.rules}
+	e.mu.Unlock()
+	return search, e.Send(p.USI())
+}
+
+// 最後にGoを送った探索の番号。プロセスを起動してから1, 2, ...と数える。
+func (e *Engine) Search() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.searches
+}
+
+func (e *Engine) PonderHit() error {
+	return e.Send("ponderhit")
+}
+
+func (e *Engine) NewGame() error {
+	return e.Send("usinewgame")
+}
+
+// gameoverで送る対局結果。
+type GameOverResult uint8
+
+const (
+	Win GameOverResult = iota
+	Lose
+	Draw
+)
+
+func (r GameOverResult) String() string {
+	switch r {
+	case Win:
+		return "win"
+	case Lose:
+		return "lose"
+	}
+	return "draw"
+}
+
+func (e *Engine) GameOver(r GameOverResult) error {
+	e.setState(Idling)
+	return e.Send("gameover " + r.String())
+}
@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGoParamsUSI(t *testing.T) {
+	tests := []struct {
+		params GoParams
+		want   string
+	}{
+		{GoParams{Infinite: true}, "go infinite"},
+		{GoParams{BTime: time.Minute, WTime: 30 * time.Second, Byoyomi: 10 * time.Second}, "go btime 60000 wtime 30000 byoyomi 10000"},
+		{GoParams{Ponder: true, BTime: time.Minute, WTime: time.Minute, BInc: time.Second, WInc: 2 * time.Second}, "go ponder btime 60000 wtime 60000 binc 1000 winc 2000"},
+		{GoParams{Depth: 10, Nodes: 100000}, "go depth 10 nodes 100000"},
+		{GoParams{MoveTime: 500 * time.Millisecond}, "go movetime 500"},
+		{GoParams{Infinite: true, SearchMoves: []string{"7g7f", "2g2f"}}, "go infinite searchmoves 7g7f 2g2f"},
+		{GoParams{Mate: 3 * time.Second, Depth: 5}, "go mate 3000"},
+		{GoParams{MateInfinite: true}, "go mate infinite"},
+	}
+	for _, test := range tests {
+		if got := test.params.USI(); got != test.want {
+			t.Errorf("want %v, got %v", test.want, got)
+		}
+	}
+}
+
+func TestGoParamsIsZero(t *testing.T) {
+	tests := []struct {
+		params GoParams
+		want   bool
+	}{
+		{GoParams{}, true},
+		{GoParams{SearchMoves: []string{}}, true},
+		{GoParams{Depth: 1}, false},
+		{GoParams{Ponder: true}, false},
+		{GoParams{SearchMoves: []string{"7g7f"}}, false},
+		{GoParams{MateInfinite: true}, false},
+	}
+	for _, test := range tests {
+		if got := test.params.IsZero(); got != test.want {
+			t.Errorf("%+v: want %v, got %v", test.params, test.want, got)
+		}
+	}
+}
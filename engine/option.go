@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type OptionType uint8
+
+const (
+	CheckOption OptionType = iota
+	SpinOption
+	ComboOption
+	ButtonOption
+	StringOption
+	FilenameOption
+)
+
+var mapUSIOptionType = map[string]OptionType{
+	"check":    CheckOption,
+	"spin":     SpinOption,
+	"combo":    ComboOption,
+	"button":   ButtonOption,
+	"string":   StringOption,
+	"filename": FilenameOption,
+}
+
+func (t OptionType) String() string {
+	for s, ot := range mapUSIOptionType {
+		if ot == t {
+			return s
+		}
+	}
+	return ""
+}
+
+// エンジンがoptionで宣言した設定項目。
+type EngineOption struct {
+	// 空白を含むことがある
+	Name    string
+	Type    OptionType
+	Default string
+	// spinの範囲
+	Min int
+	Max int
+	// comboの選択肢
+	Vars []string
+	// setoptionで設定した値。設定していなければDefault。
+	Value string
+}
+
+// "option name USI_Hash type spin default 256 min 1 max 4096"のような形式。
+// stringの"<empty>"は空文字列にする。
+func NewEngineOption(line string) (EngineOption, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "option" {
+		return EngineOption{}, fmt.Errorf("invalid option: %s", line)
+	}
+
+	// 名前は空白を含むことがあるのでtypeまで
+	typeIndex := -1
+	for i, f := range fields {
+		if f == "type" {
+			typeIndex = i
+			break
+		}
+	}
+	if len(fields) < 3 || fields[1] != "name" || typeIndex < 3 || typeIndex+1 >= len(fields) {
+		return EngineOption{}, fmt.Errorf("invalid option: %s", line)
+	}
+	option := EngineOption{Name: strings.Join(fields[2:typeIndex], " ")}
+
+	// キーワードの後に続く値を次のキーワードまで集める
+	values := map[string][]string{}
+	vars := []string{}
+	key := ""
+	for _, f := range fields[typeIndex+2:] {
+		switch f {
+		case "default", "min", "max":
+			key = f
+			values[key] = []string{}
+		case "var":
+			key = f
+			vars = append(vars, "")
+		default:
+			switch key {
+			case "":
+				return EngineOption{}, fmt.Errorf("invalid option: %s", line)
+			case "var":
+				if vars[len(vars)-1] != "" {
+					vars[len(vars)-1] += " "
+				}
+				vars[len(vars)-1] += f
+			default:
+				values[key] = append(values[key], f)
+			}
+		}
+	}
+
+	t, ok := mapUSIOptionType[fields[typeIndex+1]]
+	if !ok {
+		return EngineOption{}, fmt.Errorf("invalid option type: %s", line)
+	}
+	option.Type = t
+	option.Default = strings.Join(values["default"], " ")
+	if option.Default == "<empty>" {
+		option.Default = ""
+	}
+
+	if t == SpinOption {
+		for _, k := range []string{"min", "max"} {
+			n, err := strconv.Atoi(strings.Join(values[k], " "))
+			if err != nil {
+				return EngineOption{}, fmt.Errorf("invalid option %s: %s", k, line)
+			}
+			if k == "min" {
+				option.Min = n
+			} else {
+				option.Max = n
+			}
+		}
+	}
+	if t == ComboOption {
+		option.Vars = vars
+	}
+	option.Value = option.Default
+	return option, nil
+}
+
+// valueがこのオプションに設定できる値か確かめる。buttonは値を取らない。
+func (o EngineOption) Validate(value string) error {
+	switch o.Type {
+	case CheckOption:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("option %s: check value should be true or false: %s", o.Name, value)
+		}
+	case SpinOption:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("option %s: invalid spin value: %s", o.Name, value)
+		}
+		if n < o.Min || o.Max < n {
+			return fmt.Errorf("option %s: %d is out of range [%d, %d]", o.Name, n, o.Min, o.Max)
+		}
+	case ComboOption:
+		for _, v := range o.Vars {
+			if v == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("option %s: %s is not in %v", o.Name, value, o.Vars)
+	case ButtonOption:
+		if value != "" {
+			return fmt.Errorf("option %s: button takes no value", o.Name)
+		}
+	}
+	return nil
+}
+
+// setoptionコマンド。buttonならvalueを付けない。
+func (o EngineOption) USI(value string) string {
+	if o.Type == ButtonOption {
+		return "setoption name " + o.Name
+	}
+	if value == "" && o.Type == StringOption {
+		value = "<empty>"
+	}
+	return "setoption name " + o.Name + " value " + value
+}
@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewEngineOption(t *testing.T) {
+	tests := []struct {
+		line   string
+		option EngineOption
+	}{
+		{
+			line:   "option name USI_Hash type spin default 256 min 1 max 4096",
+			option: EngineOption{Name: "USI_Hash", Type: SpinOption, Default: "256", Min: 1, Max: 4096, Value: "256"},
+		},
+		{
+			line:   "option name USI_Ponder type check default false",
+			option: EngineOption{Name: "USI_Ponder", Type: CheckOption, Default: "false", Value: "false"},
+		},
+		{
+			line:   "option name Clear Hash type button",
+			option: EngineOption{Name: "Clear Hash", Type: ButtonOption},
+		},
+		{
+			line:   "option name Book File type filename default book/user book.db",
+			option: EngineOption{Name: "Book File", Type: FilenameOption, Default: "book/user book.db", Value: "book/user book.db"},
+		},
+		{
+			line:   "option name EvalDir type string default <empty>",
+			option: EngineOption{Name: "EvalDir", Type: StringOption},
+		},
+		{
+			line: "option name Book Move type combo default Best Move var No Book var Best Move var Random",
+			option: EngineOption{Name: "Book Move", Type: ComboOption, Default: "Best Move", Value: "Best Move",
+				Vars: []string{"No Book", "Best Move", "Random"}},
+		},
+	}
+	for _, test := range tests {
+		option, err := NewEngineOption(test.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(option, test.option) {
+			t.Errorf("NewEngineOption(%s):\n\twant\t%+v\n\tgot\t%+v", test.line, test.option, option)
+		}
+	}
+
+	for _, line := range []string{"option name Foo", "option type spin", "option name Foo type unknown", "option name Foo type spin default 1"} {
+		if _, err := NewEngineOption(line); err == nil {
+			t.Errorf("NewEngineOption(%s): want error", line)
+		}
+	}
+}
+
+func TestEngineOptionValidate(t *testing.T) {
+	spin := EngineOption{Name: "Threads", Type: SpinOption, Min: 1, Max: 8}
+	combo := EngineOption{Name: "Mode", Type: ComboOption, Vars: []string{"A", "B C"}}
+	check := EngineOption{Name: "Ponder", Type: CheckOption}
+	button := EngineOption{Name: "Clear Hash", Type: ButtonOption}
+	tests := []struct {
+		option EngineOption
+		value  string
+		ok     bool
+	}{
+		{spin, "4", true},
+		{spin, "0", false},
+		{spin, "9", false},
+		{spin, "x", false},
+		{combo, "B C", true},
+		{combo, "D", false},
+		{check, "true", true},
+		{check, "yes", false},
+		{button, "", true},
+		{button, "1", false},
+	}
+	for _, test := range tests {
+		if err := test.option.Validate(test.value); (err == nil) != test.ok {
+			t.Errorf("%s %q: want ok %v, got %v", test.option.Name, test.value, test.ok, err)
+		}
+	}
+
+	if got := button.USI(""); got != "setoption name Clear Hash" {
+		t.Errorf("button: got %s", got)
+	}
+	if got := combo.USI("B C"); got != "setoption name Mode value B C" {
+		t.Errorf("combo: got %s", got)
+	}
+}
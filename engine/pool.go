@@ -0,0 +1,206 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/eru1a/shogi-go"
+)
+
+// 解析の依頼。Limitsがゼロならctxが終わるまで解析する。
+type Job struct {
+	Position *shogi.Position
+	Limits   GoParams
+}
+
+// 解析の結果。
+type JobResult struct {
+	Job Job
+	// bestmoveを含む最後の途中経過
+	Analysis Analysis
+	Err      error
+}
+
+// 同じエンジンを複数起動し、空いているものに解析させる。
+type Pool struct {
+	newEngine func() (*Engine, error)
+	options   map[string]string
+
+	mu      sync.Mutex
+	engines []*Engine
+	// 空いているエンジンの番号
+	idle chan int
+}
+
+// 解析中に終了したエンジンを再起動してやり直す回数
+const poolRetries = 1
+
+// newEngineでn個のエンジンを作り、usiokを待ってoptionsを設定する。
+// 終了したエンジンはnewEngineで作り直す。
+func NewPool(ctx context.Context, n int, newEngine func() (*Engine, error), options map[string]string) (*Pool, error) {
+	p := &Pool{
+		newEngine: newEngine,
+		options:   options,
+		engines:   make([]*Engine, n),
+		idle:      make(chan int, n),
+	}
+	for i := 0; i < n; i++ {
+		e, err := p.start(ctx)
+		if err != nil {
+			p.Close(ctx)
+			return nil, err
+		}
+		p.engines[i] = e
+		p.idle <- i
+	}
+	return p, nil
+}
+
+func NewPoolFromConfig(ctx context.Context, n int, config EngineConfig, options map[string]string) (*Pool, error) {
+	return NewPool(ctx, n, func() (*Engine, error) {
+		return NewEngineFromConfig(config)
+	}, options)
+}
+
+func (p *Pool) start(ctx context.Context) (*Engine, error) {
+	e, err := p.newEngine()
+	if err != nil {
+		return nil, err
+	}
+	if err := e.Init(ctx); err != nil {
+		e.Close(ctx)
+		return nil, err
+	}
+	for name, value := range p.options {
+		if err := e.SetOption(name, value); err != nil {
+			e.Close(ctx)
+			return nil, err
+		}
+	}
+	if err := e.WaitReady(ctx); err != nil {
+		e.Close(ctx)
+		return nil, err
+	}
+	return e, nil
+}
+
+// jobsを空いているエンジンで解析し、jobsと同じ順の結果を返す。
+// ctxが終わったら解析中のものは止め、始めていないものはctxのエラーにする。
+func (p *Pool) Analyze(ctx context.Context, jobs []Job) []JobResult {
+	results := make([]JobResult, len(jobs))
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		if ctx.Err() != nil {
+			results[i] = JobResult{Job: job, Err: ctx.Err()}
+			continue
+		}
+		var index int
+		select {
+		case index = <-p.idle:
+		case <-ctx.Done():
+			results[i] = JobResult{Job: job, Err: ctx.Err()}
+			continue
+		}
+		wg.Add(1)
+		go func(i, index int, job Job) {
+			defer wg.Done()
+			results[i] = p.analyze(ctx, index, job)
+			p.idle <- index
+		}(i, index, job)
+	}
+	wg.Wait()
+	return results
+}
+
+func (p *Pool) analyze(ctx context.Context, index int, job Job) JobResult {
+	for retry := 0; ; retry++ {
+		e := p.engine(index)
+		// 空いている間に終了していることがある
+		if _, exited := e.Exited(); exited {
+			if err := p.restart(ctx, index); err != nil {
+				return JobResult{Job: job, Err: err}
+			}
+			e = p.engine(index)
+		}
+
+		a, err := analyze(ctx, e, job)
+		if errors.Is(err, ErrNoBestMove) {
+			// stopに応えないエンジンは思考中のままなので終了させ、次の解析で作り直す
+			kill(e)
+		}
+		if errors.Is(err, ErrExited) && retry < poolRetries {
+			if err := p.restart(ctx, index); err != nil {
+				return JobResult{Job: job, Err: err}
+			}
+			continue
+		}
+		return JobResult{Job: job, Analysis: a, Err: err}
+	}
+}
+
+func analyze(ctx context.Context, e *Engine, job Job) (Analysis, error) {
+	c, err := e.Analyze(ctx, job.Position, job.Limits)
+	if err != nil {
+		return Analysis{}, err
+	}
+	var last Analysis
+	for a := range c {
+		last = a
+	}
+	if last.Err != nil {
+		return last, last.Err
+	}
+	if ctx.Err() != nil {
+		return last, ctx.Err()
+	}
+	if !last.Done {
+		return last, errors.New("engine: analysis ended without bestmove")
+	}
+	return last, nil
+}
+
+// 待たずにエンジンを終了させる。
+func kill(e *Engine) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.Close(ctx)
+}
+
+func (p *Pool) engine(index int) *Engine {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.engines[index]
+}
+
+// 終了したエンジンを作り直す。
+func (p *Pool) restart(ctx context.Context, index int) error {
+	p.engine(index).Close(ctx)
+	e, err := p.start(ctx)
+	if err != nil {
+		return fmt.Errorf("engine: restart: %w", err)
+	}
+	p.mu.Lock()
+	p.engines[index] = e
+	p.mu.Unlock()
+	return nil
+}
+
+// 全てのエンジンを終了させる。最初のエラーを返す。
+func (p *Pool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	engines := p.engines
+	p.mu.Unlock()
+
+	var err error
+	for _, e := range engines {
+		if e == nil {
+			continue
+		}
+		if _, cerr := e.Close(ctx); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
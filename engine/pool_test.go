@@ -0,0 +1,150 @@
+package engine_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eru1a/shogi-go"
+	"github.com/eru1a/shogi-go/engine"
+	"github.com/eru1a/shogi-go/engine/enginetest"
+)
+
+func TestPool(t *testing.T) {
+	var mu sync.Mutex
+	fakes := []*enginetest.Fake{}
+	gos := 0
+	newEngine := func() (*engine.Engine, error) {
+		f := &enginetest.Fake{
+			Options: []string{"option name Threads type spin default 1 min 1 max 8"},
+			OnGo: func(position, command string) []enginetest.Line {
+				mu.Lock()
+				gos++
+				n := gos
+				mu.Unlock()
+				// 3回目の解析でエンジンが落ちる
+				if n == 3 {
+					return []enginetest.Line{{Exit: true}}
+				}
+				tree, _ := shogi.NewGameTreeFromUSI(position)
+				move := tree.Current.Position.LegalMoves()[0].USI()
+				return []enginetest.Line{
+					{Text: "info depth 1 score cp 10 pv " + move, Delay: time.Millisecond},
+					{Text: "bestmove " + move},
+				}
+			},
+		}
+		mu.Lock()
+		fakes = append(fakes, f)
+		mu.Unlock()
+		return f.Engine(), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pool, err := engine.NewPool(ctx, 3, newEngine, map[string]string{"Threads": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close(ctx)
+
+	// 1手ずつ進めた局面
+	jobs := []engine.Job{}
+	tree := shogi.NewGameTree()
+	for i := 0; i < 10; i++ {
+		jobs = append(jobs, engine.Job{Position: tree.Current.Position.Clone(), Limits: engine.GoParams{Depth: 1}})
+		tree.Move(tree.Current.Position.LegalMoves()[0])
+	}
+
+	results := pool.Analyze(ctx, jobs)
+	for i, r := range results {
+		if r.Err != nil {
+			t.Fatalf("%d: %v", i, r.Err)
+		}
+		// 結果はjobsと同じ順
+		want := jobs[i].Position.LegalMoves()[0]
+		if r.Analysis.BestMove != want || r.Analysis.Position.SFEN() != jobs[i].Position.SFEN() {
+			t.Errorf("%d: want %v, got %v", i, want, r.Analysis.BestMove)
+		}
+	}
+
+	// 落ちたエンジンを作り直し、全てのエンジンにオプションを送る
+	mu.Lock()
+	defer mu.Unlock()
+	if len(fakes) != 4 {
+		t.Errorf("want 4 engines, got %d", len(fakes))
+	}
+	for _, f := range fakes {
+		if !strings.Contains(strings.Join(f.Commands(), ","), "setoption name Threads value 2") {
+			t.Errorf("commands: %v", f.Commands())
+		}
+	}
+}
+
+func TestPoolCancel(t *testing.T) {
+	newEngine := func() (*engine.Engine, error) {
+		f := &enginetest.Fake{OnGo: func(position, command string) []enginetest.Line {
+			return []enginetest.Line{{Text: "info depth 1 score cp 0", Delay: time.Hour}}
+		}}
+		return f.Engine(), nil
+	}
+	pool, err := engine.NewPool(context.Background(), 1, newEngine, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	jobs := []engine.Job{{Position: shogi.NewPosition()}, {Position: shogi.NewPosition()}}
+	for i, r := range pool.Analyze(ctx, jobs) {
+		if r.Err != context.DeadlineExceeded {
+			t.Errorf("%d: want %v, got %v", i, context.DeadlineExceeded, r.Err)
+		}
+	}
+}
+
+func TestPoolNoBestMove(t *testing.T) {
+	defer engine.SetStopTimeout(50 * time.Millisecond)()
+
+	var mu sync.Mutex
+	engines := 0
+	newEngine := func() (*engine.Engine, error) {
+		mu.Lock()
+		engines++
+		// 最初のエンジンはstopを無視する
+		f := &enginetest.Fake{IgnoreStop: engines == 1, OnGo: enginetest.Reply("info depth 1 score cp 0 pv 7g7f")}
+		if engines > 1 {
+			f.OnGo = enginetest.Reply("info depth 1 score cp 0 pv 7g7f", "bestmove 7g7f")
+		}
+		mu.Unlock()
+		return f.Engine(), nil
+	}
+	pool, err := engine.NewPool(context.Background(), 1, newEngine, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	jobs := []engine.Job{{Position: shogi.NewPosition()}}
+	if r := pool.Analyze(ctx, jobs)[0]; r.Err != engine.ErrNoBestMove {
+		t.Fatalf("want %v, got %v", engine.ErrNoBestMove, r.Err)
+	}
+
+	// 思考中のまま残らず、作り直したエンジンで解析する
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	jobs = []engine.Job{{Position: shogi.NewPosition(), Limits: engine.GoParams{Depth: 1}}}
+	if r := pool.Analyze(ctx, jobs)[0]; r.Err != nil || r.Analysis.BestMove != usiMoves(t, "7g7f")[0] {
+		t.Errorf("got %v, %v", r.Analysis.BestMove, r.Err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if engines != 2 {
+		t.Errorf("want 2 engines, got %d", engines)
+	}
+}
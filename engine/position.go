@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eru1a/shogi-go"
+)
+
+// pからmovesを指した局面をpositionで送る。
+// 送った局面はbestmoveとpvの検証に使う。
+func (e *Engine) SetPosition(p *shogi.Position, moves []shogi.Move) error {
+	current := p.Clone()
+	usi := []string{}
+	for _, m := range moves {
+		if err := current.Move(m); err != nil {
+			return fmt.Errorf("engine: %w", err)
+		}
+		usi = append(usi, m.USI())
+	}
+
+	command := "position "
+	if sfen := p.SFEN(); sfen == shogi.NewPosition().SFEN() {
+		command += "startpos"
+	} else {
+		command += "sfen " + sfen
+	}
+	if len(usi) > 0 {
+		command += " moves " + strings.Join(usi, " ")
+	}
+	return e.setPosition(command, current, shogi.DefaultRules)
+}
+
+// treeのRootからCurrentまでをpositionで送る。投了などの指し手は含まない。
+// bestmoveとpvはtreeのRulesで検証する。
+func (e *Engine) SetGameTree(tree *shogi.GameTree) error {
+	current := tree.Root.Position
+	for _, n := range tree.Path()[1:] {
+		if n.MoveData.IsTerminal() {
+			break
+		}
+		current = n.Position
+	}
+	return e.setPosition(tree.USI(), current.Clone(), tree.Rules)
+}
+
+func (e *Engine) setPosition(command string, p *shogi.Position, rules shogi.Rules) error {
+	e.mu.Lock()
+	e.position = p
+	e.rules = rules
+	e.mu.Unlock()
+	return e.Send(command)
+}
+
+// SetPositionで送った局面。送っていなければnil。
+func (e *Engine) Position() *shogi.Position {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.position == nil {
+		return nil
+	}
+	return e.position.Clone()
+}
+
+// 探索の局面と検証に使うルール。局面を送っていなければnil。
+func (e *Engine) searchPosition(search int) *searchPosition {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sp, ok := e.searchPositions[search]
+	if !ok || sp.position == nil {
+		return nil
+	}
+	return &sp
+}
+
+// bestmoveを探索した局面の指し手にする。投了はToryoMove、入玉宣言はKachiMove、
+// 指せない手はIllegalMoveにしてIllegalを立てる。局面を送っていなければ何もしない。
+func (e *Engine) validateBestMove(ev *Event) {
+	sp := e.searchPosition(ev.Search)
+	if sp == nil {
+		return
+	}
+
+	switch ev.BestMove.BestMove {
+	case "resign":
+		ev.Move = shogi.ToryoMove
+		return
+	case "win":
+		ev.Move = shogi.KachiMove
+		return
+	}
+	next := sp.position.Clone()
+	m, err := shogi.NewMoveFromUSI(ev.BestMove.BestMove)
+	if err != nil || sp.rules.Move(next, m) != nil {
+		ev.Move = shogi.IllegalMove
+		ev.Illegal = true
+		return
+	}
+	ev.Move = m
+
+	if ev.BestMove.Ponder != "" {
+		if ponder, err := shogi.NewMoveFromUSI(ev.BestMove.Ponder); err == nil && sp.rules.IsLegalMove(next, ponder) {
+			ev.Ponder = ponder
+		}
+	}
+}
+
+// infoのpvを探索した局面から指せる所までの指し手にする。指せない手があればtrueを返す。
+// 局面を送っていなければnilを返す。呼ぶたびに探索した局面のルールで検証する。
+func (ev Event) Pv() ([]shogi.Move, bool) {
+	if ev.search == nil {
+		return nil, false
+	}
+	p := ev.search.position.Clone()
+	moves := []shogi.Move{}
+	for _, usi := range ev.Info.Pv {
+		m, err := shogi.NewMoveFromUSI(usi)
+		if err != nil || ev.search.rules.Move(p, m) != nil {
+			return moves, true
+		}
+		moves = append(moves, m)
+	}
+	return moves, false
+}
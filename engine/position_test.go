@@ -0,0 +1,181 @@
+package engine_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eru1a/shogi-go"
+	"github.com/eru1a/shogi-go/engine"
+	"github.com/eru1a/shogi-go/engine/enginetest"
+)
+
+func usiMoves(t *testing.T, usi ...string) []shogi.Move {
+	t.Helper()
+	moves := []shogi.Move{}
+	for _, u := range usi {
+		m, err := shogi.NewMoveFromUSI(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		moves = append(moves, m)
+	}
+	return moves
+}
+
+func TestEngineSetPosition(t *testing.T) {
+	f := &enginetest.Fake{}
+	e := f.Engine()
+	defer e.Close(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := e.SetPosition(shogi.NewPosition(), usiMoves(t, "7g7f", "3c3d")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetPosition(shogi.NewPosition(), usiMoves(t, "7g7f", "7g7f")); err == nil {
+		t.Errorf("want illegal move error")
+	}
+
+	tree, err := shogi.NewGameTreeFromUSI("position sfen 4k4/9/9/9/9/9/9/9/4K4 b G 1 moves 5i5h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Move(shogi.ToryoMove)
+	if err := e.SetGameTree(tree); err != nil {
+		t.Fatal(err)
+	}
+	if p := e.Position(); p.Turn != shogi.White || p.Ply != 1 {
+		t.Errorf("position: got %v", p)
+	}
+
+	if _, err := f.WaitCommand(ctx, "position sfen"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"position startpos moves 7g7f 3c3d",
+		"position sfen 4k4/9/9/9/9/9/9/9/4K4 b G 1 moves 5i5h",
+	}
+	if got := f.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestEngineValidateMoves(t *testing.T) {
+	tests := []struct {
+		lines   []string
+		move    shogi.Move
+		ponder  shogi.Move
+		pv      []string
+		illegal bool
+	}{
+		{
+			lines:  []string{"info depth 1 score cp 0 pv 7g7f 3c3d", "bestmove 7g7f ponder 3c3d"},
+			move:   usiMoves(t, "7g7f")[0],
+			ponder: usiMoves(t, "3c3d")[0],
+			pv:     []string{"7g7f", "3c3d"},
+		},
+		{
+			// pvは指せる所まで
+			lines:   []string{"info depth 1 score cp 0 pv 7g7f 7g7f", "bestmove 7g7f ponder 7g7f"},
+			move:    usiMoves(t, "7g7f")[0],
+			pv:      []string{"7g7f"},
+			illegal: true,
+		},
+		{
+			lines:   []string{"bestmove 5e5d"},
+			move:    shogi.IllegalMove,
+			illegal: true,
+		},
+		{
+			lines: []string{"bestmove resign"},
+			move:  shogi.ToryoMove,
+		},
+	}
+
+	for _, test := range tests {
+		f := &enginetest.Fake{OnGo: enginetest.Reply(test.lines...)}
+		e := f.Engine()
+		events, unsubscribe := e.Subscribe()
+		if err := e.SetPosition(shogi.NewPosition(), nil); err != nil {
+			t.Fatal(err)
+		}
+		search, err := e.Go(engine.GoParams{Depth: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		illegal := false
+		pv := []string{}
+		for ev := range events {
+			if ev.Search != search {
+				t.Errorf("%v: want search %d, got %d", test.lines, search, ev.Search)
+			}
+			if ev.Type == engine.InfoEvent {
+				moves, illegalPv := ev.Pv()
+				for _, m := range moves {
+					pv = append(pv, m.USI())
+				}
+				illegal = illegal || illegalPv
+			}
+			if ev.Type == engine.BestMoveEvent {
+				if ev.Move != test.move || ev.Ponder != test.ponder {
+					t.Errorf("%v: want %v %v, got %v %v", test.lines, test.move, test.ponder, ev.Move, ev.Ponder)
+				}
+				illegal = illegal || ev.Illegal
+				break
+			}
+		}
+		if len(test.pv) > 0 && !reflect.DeepEqual(pv, test.pv) {
+			t.Errorf("%v: want pv %v, got %v", test.lines, test.pv, pv)
+		}
+		if illegal != test.illegal {
+			t.Errorf("%v: want illegal %v, got %v", test.lines, test.illegal, illegal)
+		}
+		unsubscribe()
+		e.Close(context.Background())
+	}
+}
+
+func TestEngineValidateRules(t *testing.T) {
+	// 打ち歩詰め
+	sfen := "7lk/7p1/9/7N1/9/9/9/9/K8 b P 1"
+	drop := usiMoves(t, "P*1b")[0]
+	for _, forbid := range []bool{true, false} {
+		tree, err := shogi.NewGameTreeFromSFEN(sfen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.Rules = shogi.Rules{ForbidPawnDropMate: forbid}
+		wantPv := 1
+		if forbid {
+			wantPv = 0
+		}
+
+		f := &enginetest.Fake{OnGo: enginetest.Reply("info depth 1 score mate 1 pv P*1b", "bestmove P*1b")}
+		e := f.Engine()
+		events, unsubscribe := e.Subscribe()
+		if err := e.SetGameTree(tree); err != nil {
+			t.Fatal(err)
+		}
+		e.Go(engine.GoParams{Depth: 1})
+
+		for ev := range events {
+			if ev.Type == engine.InfoEvent {
+				pv, illegal := ev.Pv()
+				if illegal != forbid || len(pv) != wantPv {
+					t.Errorf("forbid %v: got pv %v, illegal %v", forbid, pv, illegal)
+				}
+			}
+			if ev.Type == engine.BestMoveEvent {
+				if ev.Illegal != forbid || (!forbid && ev.Move != drop) {
+					t.Errorf("forbid %v: got %v, illegal %v", forbid, ev.Move, ev.Illegal)
+				}
+				break
+			}
+		}
+		unsubscribe()
+		e.Close(context.Background())
+	}
+}
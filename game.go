@@ -1,26 +1,47 @@
 package shogi
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
 type GameTree struct {
 	Root    *GameNode
 	Current *GameNode
+	Info    GameInfo
+	// 合法手や終局の判定に使うルール
+	Rules Rules
+
+	// 最後に終局の判定をしたノードの合法手。次の指し手の検査で使い回す。
+	legalNode  *GameNode
+	legalRules Rules
+	legalMoves []Move
 }
 
 type GameNode struct {
-	Prev     *GameNode
-	Next     *GameNode
-	Position *Position
-	MoveData MoveData
+	Prev *GameNode
+	// 本譜の次の局面
+	Next *GameNode
+	// Next以外の変化
+	Variations []*GameNode
+	Position   *Position
+	MoveData   MoveData
+	Comments   []string
+	// この指し手の消費時間
+	Time time.Duration
+	// 指した側の累計消費時間
+	TotalTime time.Duration
+	// エンジンの評価。なければnil。
+	Eval  *Evaluation
+	Glyph Glyph
+	// ルールによる自動の終局判定で追加されたノードならtrue
+	adjudicated bool
 }
 
 func NewGameTree() *GameTree {
-	p := NewPosition()
-	n := NewGameNode(nil, p, InitialMoveData)
-	return &GameTree{
-		Root:    n,
-		Current: n,
-	}
+	return NewGameTreeFromPosition(NewPosition())
 }
 
 func NewGameTreeFromSFEN(sfen string) (*GameTree, error) {
@@ -28,10 +49,75 @@ func NewGameTreeFromSFEN(sfen string) (*GameTree, error) {
 	if err != nil {
 		return nil, fmt.Errorf("NewGameTreeFromSFEN: %v", err)
 	}
+	return NewGameTreeFromPosition(p), nil
+}
+
+func NewGameTreeFromPosition(p *Position) *GameTree {
 	n := NewGameNode(nil, p, InitialMoveData)
-	return &GameTree{Root: n, Current: n}, nil
+	return &GameTree{
+		Root:    n,
+		Current: n,
+		Info:    NewGameInfo(),
+		Rules:   DefaultRules,
+	}
+}
+
+// USIのpositionコマンドから棋譜を作る。
+// "position startpos moves 7g7f 3c3d"や"position sfen <sfen> moves ..."の形式。
+// 先頭の"position"は省略できる。CurrentはRootから指し手を進めた局面になる。
+func NewGameTreeFromUSI(usi string) (*GameTree, error) {
+	fields := strings.Fields(usi)
+	if len(fields) > 0 && fields[0] == "position" {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("NewGameTreeFromUSI: startpos or sfen not found: %s", usi)
+	}
+
+	var tree *GameTree
+	switch fields[0] {
+	case "startpos":
+		tree = NewGameTree()
+		fields = fields[1:]
+	case "sfen":
+		// 手数は省略されることがある
+		n := 1
+		for n < len(fields) && n <= 4 && fields[n] != "moves" {
+			n++
+		}
+		sfen := strings.Join(fields[1:n], " ")
+		if n == 4 {
+			sfen += " 1"
+		}
+		var err error
+		tree, err = NewGameTreeFromSFEN(sfen)
+		if err != nil {
+			return nil, fmt.Errorf("NewGameTreeFromUSI: %v", err)
+		}
+		fields = fields[n:]
+	default:
+		return nil, fmt.Errorf("NewGameTreeFromUSI: startpos or sfen not found: %s", usi)
+	}
+
+	if len(fields) == 0 {
+		return tree, nil
+	}
+	if fields[0] != "moves" {
+		return nil, fmt.Errorf("NewGameTreeFromUSI: unknown token %s: %s", fields[0], usi)
+	}
+	for _, f := range fields[1:] {
+		m, err := NewMoveFromUSI(f)
+		if err != nil {
+			return nil, fmt.Errorf("NewGameTreeFromUSI: %v", err)
+		}
+		if err := tree.Move(m); err != nil {
+			return nil, fmt.Errorf("NewGameTreeFromUSI: %v", err)
+		}
+	}
+	return tree, nil
 }
 
+// prevの次のノードを作る。prevに既にNextがあればNextは変えずに変化に加える。
 func NewGameNode(prev *GameNode, p *Position, m MoveData) *GameNode {
 	n := &GameNode{
 		Prev:     prev,
@@ -40,29 +126,142 @@ func NewGameNode(prev *GameNode, p *Position, m MoveData) *GameNode {
 		MoveData: m,
 	}
 	if prev != nil {
-		prev.Next = n
+		if prev.Next == nil {
+			prev.Next = n
+		} else {
+			prev.Variations = append(prev.Variations, n)
+		}
 	}
 	return n
 }
 
+// Currentから指し手mを指す。
+// 既にNextがあって別の指し手なら変化として追加し、Currentを変化に進める。
+// Nextとその先の指し手は置き換えずに本譜として残す。
+// 入玉宣言(KachiMove)はRulesで判定して持将棋や反則負けにする。
 func (t *GameTree) Move(m Move) error {
-	// Nextが同じ指し手だったら単に進める
+	return t.move(m, true)
+}
+
+// 棋譜から読み込んだ指し手を指す。棋譜に書かれた入玉宣言の結果はそのまま残す。
+func (t *GameTree) readMove(m Move) error {
+	return t.move(m, false)
+}
+
+func (t *GameTree) move(m Move, declare bool) error {
+	// 開始局面やNullMoveは指せない
+	if !m.IsNormalMove() && !m.IsDropMove() && !m.IsTerminal() {
+		return fmt.Errorf("not a move: kind %d", m.Kind)
+	}
+	// Nextか変化に同じ指し手があったら単に進める
 	if t.Current.Next != nil && m == t.Current.Next.MoveData.Move {
 		t.Next()
 		return nil
 	}
+	for _, v := range t.Current.Variations {
+		if m == v.MoveData.Move {
+			t.Current = v
+			return nil
+		}
+	}
+
+	if t.Current.MoveData.IsTerminal() {
+		// 自動で判定した終局は棋譜に書かれた終局(投了など)で置き換える
+		if t.Current.adjudicated && m.IsTerminal() {
+			t.Current.MoveData = NewMoveData(m, t.Current.Prev.Position, NullSquare)
+			t.Current.adjudicated = false
+			return nil
+		}
+		return errors.New("game is already over")
+	}
+
+	p := t.Current.Position.Clone()
+	if m.IsNormalMove() || m.IsDropMove() {
+		if !containsMove(t.legalMovesOf(t.Current), m) {
+			return fmt.Errorf("illegal move: %v", m.USI())
+		}
+	}
+	// 入玉宣言はルールで判定して持将棋や反則負けにする
+	if declare && m.Kind == KachiMoveKind {
+		switch t.Rules.Declare(p).Reason {
+		case JishogiMoveKind:
+			m = JishogiMove
+		case IllegalMoveKind:
+			m = IllegalMove
+		}
+	}
 
 	before := t.Current.MoveData.To
 	moveData := NewMoveData(m, t.Current.Position, before)
 
-	p := t.Current.Position.Clone()
-	if err := p.Move(m); err != nil {
-		return err
+	// 投了などは局面を変えない
+	if !m.IsTerminal() {
+		if err := p.move(m); err != nil {
+			return err
+		}
 	}
 	t.Current = NewGameNode(t.Current, p, moveData)
+
+	if !m.IsTerminal() {
+		if moveData, ok := t.Rules.adjudicate(t.Current, t.legalMovesOf(t.Current)); ok {
+			t.Current = NewGameNode(t.Current, p.Clone(), moveData)
+			t.Current.adjudicated = true
+		}
+	}
 	return nil
 }
 
+// nの局面の合法手。直前に同じノードで生成していればそれを返す。
+func (t *GameTree) legalMovesOf(n *GameNode) []Move {
+	if t.legalNode != n || t.legalRules != t.Rules {
+		t.legalNode = n
+		t.legalRules = t.Rules
+		t.legalMoves = t.Rules.LegalMoves(n.Position)
+	}
+	return t.legalMoves
+}
+
+func containsMove(moves []Move, m Move) bool {
+	for _, move := range moves {
+		if m == move {
+			return true
+		}
+	}
+	return false
+}
+
+// 千日手の判定に使う手数を除いた局面。
+func (n *GameNode) positionKey() string {
+	p := n.Position
+	return p.Board.SFEN() + " " + p.Turn.USI() + " " + p.Hand.SFEN()
+}
+
+// Currentの消費時間を設定し、累計消費時間を計算する。
+// 棋譜の注釈(消費時間やコメントなど)を付けるノード。
+// 指し手で詰みなどが自動で追加されていれば、その指し手のノード。
+func (t *GameTree) annotated() *GameNode {
+	if t.Current.adjudicated {
+		return t.Current.Prev
+	}
+	return t.Current
+}
+
+func (t *GameTree) SetTime(d time.Duration) {
+	t.Current.SetTime(d)
+}
+
+// 消費時間を設定し、累計消費時間を計算する。
+func (n *GameNode) SetTime(d time.Duration) {
+	n.Time = d
+	n.TotalTime = d
+	for prev := n.Prev; prev != nil; prev = prev.Prev {
+		if prev.MoveData.Color == n.MoveData.Color {
+			n.TotalTime += prev.TotalTime
+			break
+		}
+	}
+}
+
 // Currentを次の局面に進める。
 // 成功したらtrue。
 func (t *GameTree) Next() bool {
@@ -95,3 +294,39 @@ func (t *GameTree) GotoNth(n int) {
 	}
 	t.Current = current
 }
+
+// RootからCurrentまでのノード。
+func (t *GameTree) Path() []*GameNode {
+	path := []*GameNode{}
+	for n := t.Current; n != nil; n = n.Prev {
+		path = append(path, n)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// RootからCurrentまでをUSIのpositionコマンドにする。
+// 投了などの指し手は含まない。
+func (t *GameTree) USI() string {
+	var s strings.Builder
+	s.WriteString("position ")
+	if sfen := t.Root.Position.SFEN(); sfen == NewPosition().SFEN() {
+		s.WriteString("startpos")
+	} else {
+		s.WriteString("sfen " + sfen)
+	}
+
+	moves := []string{}
+	for _, n := range t.Path()[1:] {
+		if n.MoveData.IsTerminal() {
+			break
+		}
+		moves = append(moves, n.MoveData.USI())
+	}
+	if len(moves) > 0 {
+		s.WriteString(" moves " + strings.Join(moves, " "))
+	}
+	return s.String()
+}
@@ -0,0 +1,240 @@
+package shogi
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 対局情報。
+// 棋譜形式ごとのヘッダはKIFのヘッダ名(先手、後手、棋戦など)で読み書きする。
+type GameInfo struct {
+	Black       string // 先手(下手)
+	White       string // 後手(上手)
+	Event       string // 棋戦
+	Site        string // 場所
+	StartTime   time.Time
+	EndTime     time.Time
+	TimeControl TimeControl
+	Handicap    string // 手合割
+	Opening     string // 戦型
+	Result      string // 結果
+	// 上記以外のヘッダや解釈できなかったヘッダ
+	Extra map[string]string
+}
+
+// 持ち時間。
+type TimeControl struct {
+	Main      time.Duration
+	Byoyomi   time.Duration
+	Increment time.Duration
+}
+
+// 手合割と開始局面。駒落ちは上手(後手)から指す。
+var handicaps = []struct {
+	name string
+	jkf  string
+	sfen string
+}{
+	{"平手", "HIRATE", "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1"},
+	{"香落ち", "KY", "lnsgkgsn1/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1"},
+	{"右香落ち", "KY_R", "1nsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1"},
+	{"角落ち", "KA", "lnsgkgsnl/1r7/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1"},
+	{"飛車落ち", "HI", "lnsgkgsnl/7b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1"},
+	{"飛香落ち", "HIKY", "lnsgkgsn1/7b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1"},
+	{"二枚落ち", "2", "lnsgkgsnl/9/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1"},
+	{"三枚落ち", "3", "lnsgkgsn1/9/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1"},
+	{"四枚落ち", "4", "1nsgkgsn1/9/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1"},
+	{"六枚落ち", "6", "2sgkgs2/9/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1"},
+	{"八枚落ち", "8", "3gkg3/9/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1"},
+	{"十枚落ち", "10", "4k4/9/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1"},
+}
+
+// 開始局面から手合割の名前を返す。当てはまらなければ空文字列。
+func handicapName(p *Position) string {
+	sfen := p.SFEN()
+	for _, h := range handicaps {
+		if h.sfen == sfen {
+			return h.name
+		}
+	}
+	return ""
+}
+
+var dateLayouts = []string{
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006/01/02",
+	time.RFC3339,
+}
+
+func NewGameInfo() GameInfo {
+	return GameInfo{Extra: make(map[string]string)}
+}
+
+// KIFのヘッダ名で値を設定する。
+// 日時や持ち時間が解釈できなければExtraに入れる。
+func (i *GameInfo) Set(key, value string) {
+	if i.Extra == nil {
+		i.Extra = make(map[string]string)
+	}
+	switch key {
+	case "先手", "下手":
+		i.Black = value
+	case "後手", "上手":
+		i.White = value
+	case "棋戦":
+		i.Event = value
+	case "場所":
+		i.Site = value
+	case "開始日時":
+		if t, ok := parseDate(value); ok {
+			i.StartTime = t
+			return
+		}
+		i.Extra[key] = value
+	case "終了日時":
+		if t, ok := parseDate(value); ok {
+			i.EndTime = t
+			return
+		}
+		i.Extra[key] = value
+	case "持ち時間":
+		if tc, err := NewTimeControl(value); err == nil {
+			i.TimeControl = tc
+			return
+		}
+		i.Extra[key] = value
+	case "手合割":
+		i.Handicap = value
+	case "戦型":
+		i.Opening = value
+	case "結果":
+		i.Result = value
+	default:
+		i.Extra[key] = value
+	}
+}
+
+// KIFのヘッダ名をキーにした全ての値。空の値は含まない。
+func (i *GameInfo) Header() map[string]string {
+	header := make(map[string]string)
+	for key, value := range i.Extra {
+		header[key] = value
+	}
+	set := func(key, value string) {
+		if value != "" {
+			header[key] = value
+		}
+	}
+	set("先手", i.Black)
+	set("後手", i.White)
+	set("棋戦", i.Event)
+	set("場所", i.Site)
+	set("開始日時", formatDate(i.StartTime))
+	set("終了日時", formatDate(i.EndTime))
+	set("持ち時間", i.TimeControl.String())
+	set("手合割", i.Handicap)
+	set("戦型", i.Opening)
+	set("結果", i.Result)
+	return header
+}
+
+func parseDate(s string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(dateLayouts[0])
+}
+
+var reJapaneseDuration = regexp.MustCompile(`^(?:(\d+)時間)?(?:(\d+)分)?(?:(\d+)秒)?$`)
+
+// "10分+秒読み30秒+加算5秒"のような形式。"各"は省略できる。
+// "10分+30秒"のように名前がなければ秒読みとする。
+func NewTimeControl(s string) (TimeControl, error) {
+	var tc TimeControl
+	parts := strings.Split(strings.TrimPrefix(s, "各"), "+")
+	for n, part := range parts {
+		dst := &tc.Main
+		switch {
+		case n == 0:
+		case strings.HasPrefix(part, "秒読み"):
+			part = strings.TrimPrefix(part, "秒読み")
+			dst = &tc.Byoyomi
+		case strings.HasPrefix(part, "加算"):
+			part = strings.TrimPrefix(part, "加算")
+			dst = &tc.Increment
+		default:
+			dst = &tc.Byoyomi
+		}
+		d, err := parseJapaneseDuration(part)
+		if err != nil {
+			return TimeControl{}, fmt.Errorf("invalid time control: %s", s)
+		}
+		*dst = d
+	}
+	return tc, nil
+}
+
+func parseJapaneseDuration(s string) (time.Duration, error) {
+	m := reJapaneseDuration.FindStringSubmatch(s)
+	if s == "" || m == nil {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(m[i+1])
+		d += time.Duration(n) * unit
+	}
+	return d, nil
+}
+
+func formatJapaneseDuration(d time.Duration) string {
+	if d == 0 {
+		return "0秒"
+	}
+	var s strings.Builder
+	if h := d / time.Hour; h > 0 {
+		s.WriteString(fmt.Sprintf("%d時間", h))
+	}
+	if m := d % time.Hour / time.Minute; m > 0 {
+		s.WriteString(fmt.Sprintf("%d分", m))
+	}
+	if sec := d % time.Minute / time.Second; sec > 0 {
+		s.WriteString(fmt.Sprintf("%d秒", sec))
+	}
+	return s.String()
+}
+
+func (tc TimeControl) IsZero() bool {
+	return tc == TimeControl{}
+}
+
+// 持ち時間がなければ空文字列。
+func (tc TimeControl) String() string {
+	if tc.IsZero() {
+		return ""
+	}
+	s := formatJapaneseDuration(tc.Main)
+	if tc.Byoyomi > 0 {
+		s += "+秒読み" + formatJapaneseDuration(tc.Byoyomi)
+	}
+	if tc.Increment > 0 {
+		s += "+加算" + formatJapaneseDuration(tc.Increment)
+	}
+	return s
+}
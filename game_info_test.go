@@ -0,0 +1,93 @@
+package shogi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGameInfo(t *testing.T) {
+	header := map[string]string{
+		"先手":   "A",
+		"後手":   "B",
+		"棋戦":   "test",
+		"場所":   "東京",
+		"開始日時": "2020/01/02 03:04:05",
+		"終了日時": "2020/01/02 05:00:00",
+		"持ち時間": "1時間30分+秒読み1分",
+		"手合割":  "平手",
+		"戦型":   "矢倉",
+		"結果":   "先手勝ち",
+		"備考":   "memo",
+	}
+	info := NewGameInfo()
+	for key, value := range header {
+		info.Set(key, value)
+	}
+
+	want := GameInfo{
+		Black:       "A",
+		White:       "B",
+		Event:       "test",
+		Site:        "東京",
+		StartTime:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local),
+		EndTime:     time.Date(2020, 1, 2, 5, 0, 0, 0, time.Local),
+		TimeControl: TimeControl{Main: 90 * time.Minute, Byoyomi: time.Minute},
+		Handicap:    "平手",
+		Opening:     "矢倉",
+		Result:      "先手勝ち",
+		Extra:       map[string]string{"備考": "memo"},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("Set:\nwant %+v\ngot %+v", want, info)
+	}
+	if got := info.Header(); !reflect.DeepEqual(got, header) {
+		t.Errorf("Header():\nwant %v\ngot %v", header, got)
+	}
+
+	// 解釈できない値はExtraに残る
+	info = NewGameInfo()
+	info.Set("開始日時", "令和2年1月2日")
+	info.Set("持ち時間", "各10分(切れ負け)")
+	info.Set("下手", "C")
+	if !info.StartTime.IsZero() || !info.TimeControl.IsZero() || info.Black != "C" {
+		t.Errorf("got %+v", info)
+	}
+	want2 := map[string]string{"先手": "C", "開始日時": "令和2年1月2日", "持ち時間": "各10分(切れ負け)"}
+	if got := info.Header(); !reflect.DeepEqual(got, want2) {
+		t.Errorf("Header():\nwant %v\ngot %v", want2, got)
+	}
+}
+
+func TestTimeControl(t *testing.T) {
+	tests := []struct {
+		s    string
+		tc   TimeControl
+		want string
+	}{
+		{"10分", TimeControl{Main: 10 * time.Minute}, "10分"},
+		{"各2時間", TimeControl{Main: 2 * time.Hour}, "2時間"},
+		{"10分+30秒", TimeControl{Main: 10 * time.Minute, Byoyomi: 30 * time.Second}, "10分+秒読み30秒"},
+		{"0秒+秒読み10秒", TimeControl{Byoyomi: 10 * time.Second}, "0秒+秒読み10秒"},
+		{"5分+加算10秒", TimeControl{Main: 5 * time.Minute, Increment: 10 * time.Second}, "5分+加算10秒"},
+		{"1時間1分1秒+秒読み1分+加算1秒", TimeControl{Main: time.Hour + time.Minute + time.Second, Byoyomi: time.Minute, Increment: time.Second}, "1時間1分1秒+秒読み1分+加算1秒"},
+	}
+	for _, test := range tests {
+		tc, err := NewTimeControl(test.s)
+		if err != nil {
+			t.Errorf("NewTimeControl(%v): %v", test.s, err)
+		}
+		if tc != test.tc {
+			t.Errorf("NewTimeControl(%v): want %+v, got %+v", test.s, test.tc, tc)
+		}
+		if s := tc.String(); s != test.want {
+			t.Errorf("%+v.String(): want %v, got %v", tc, test.want, s)
+		}
+	}
+
+	for _, s := range []string{"", "10", "10分+", "10分(切れ負け)"} {
+		if _, err := NewTimeControl(s); err == nil {
+			t.Errorf("NewTimeControl(%v): want error", s)
+		}
+	}
+}
@@ -50,3 +50,131 @@ func TestGame(t *testing.T) {
 	}
 
 }
+
+func TestGameVariation(t *testing.T) {
+	tree := NewGameTree()
+	for _, usi := range []string{"7g7f", "3c3d"} {
+		m, _ := NewMoveFromUSI(usi)
+		if err := tree.Move(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree.GotoNth(1)
+	for _, usi := range []string{"8c8d", "3c3d"} {
+		tree.GotoNth(1)
+		m, _ := NewMoveFromUSI(usi)
+		if err := tree.Move(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n := tree.Root.Next
+	if got := n.Next.MoveData.USI(); got != "3c3d" {
+		t.Errorf("Next: want 3c3d, got %v", got)
+	}
+	if len(n.Variations) != 1 || n.Variations[0].MoveData.USI() != "8c8d" {
+		t.Errorf("Variations: want [8c8d], got %v", n.Variations)
+	}
+	if n.Variations[0].Prev != n {
+		t.Errorf("Prev of a variation should be the branch point")
+	}
+}
+
+func TestGameUSI(t *testing.T) {
+	tests := []struct {
+		usi     string
+		sfen    string
+		wantUSI string
+	}{
+		{
+			usi:     "position startpos",
+			sfen:    "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1",
+			wantUSI: "position startpos",
+		},
+		{
+			usi:     "position startpos moves 7g7f 3c3d 8h2b+",
+			sfen:    "lnsgkgsnl/1r5+B1/pppppp1pp/6p2/9/2P6/PP1PPPPPP/7R1/LNSGKGSNL w B 4",
+			wantUSI: "position startpos moves 7g7f 3c3d 8h2b+",
+		},
+		{
+			usi:     "sfen lnsgkgsnl/1r5+B1/pppppp1pp/6p2/9/2P6/PP1PPPPPP/7R1/LNSGKGSNL w B 4 moves 3a2b B*5e",
+			sfen:    "lnsgkg1nl/1r5s1/pppppp1pp/6p2/4B4/2P6/PP1PPPPPP/7R1/LNSGKGSNL w b 6",
+			wantUSI: "position sfen lnsgkgsnl/1r5+B1/pppppp1pp/6p2/9/2P6/PP1PPPPPP/7R1/LNSGKGSNL w B 4 moves 3a2b B*5e",
+		},
+		{
+			// 手数の省略
+			usi:     "position sfen lnsgkgsnl/9/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - moves 5a4b",
+			sfen:    "lnsg1gsnl/5k3/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 2",
+			wantUSI: "position sfen lnsgkgsnl/9/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1 moves 5a4b",
+		},
+	}
+	for _, test := range tests {
+		tree, err := NewGameTreeFromUSI(test.usi)
+		if err != nil {
+			t.Fatalf("NewGameTreeFromUSI(%v): %v", test.usi, err)
+		}
+		if sfen := tree.Current.Position.SFEN(); sfen != test.sfen {
+			t.Errorf("NewGameTreeFromUSI(%v): want %v, got %v", test.usi, test.sfen, sfen)
+		}
+		if usi := tree.USI(); usi != test.wantUSI {
+			t.Errorf("USI(): want %v, got %v", test.wantUSI, usi)
+		}
+	}
+
+	for _, usi := range []string{"", "position", "position startpos 7g7f", "position startpos moves 7g7e", "position sfen foo"} {
+		if _, err := NewGameTreeFromUSI(usi); err == nil {
+			t.Errorf("NewGameTreeFromUSI(%v): want error", usi)
+		}
+	}
+
+	// Currentまでの指し手だけを出力する
+	tree, _ := NewGameTreeFromUSI("position startpos moves 7g7f 3c3d")
+	tree.Move(ToryoMove)
+	if want := "position startpos moves 7g7f 3c3d"; tree.USI() != want {
+		t.Errorf("USI(): want %v, got %v", want, tree.USI())
+	}
+	tree.Prev()
+	tree.Prev()
+	if want := "position startpos moves 7g7f"; tree.USI() != want {
+		t.Errorf("USI(): want %v, got %v", want, tree.USI())
+	}
+}
+
+func TestGameMoveKeepsNext(t *testing.T) {
+	tree, err := NewGameTreeFromUSI("startpos moves 7g7f 3c3d 2g2f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.GotoNth(1)
+	m, _ := NewMoveFromUSI("8c8d")
+	if err := tree.Move(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := tree.Current.MoveData.USI(); got != "8c8d" {
+		t.Errorf("Current: want 8c8d, got %v", got)
+	}
+
+	// 本譜は先の指し手まで残る
+	mainline := []string{}
+	for n := tree.Root.Next; n != nil; n = n.Next {
+		mainline = append(mainline, n.MoveData.USI())
+	}
+	if want := []string{"7g7f", "3c3d", "2g2f"}; !reflect.DeepEqual(mainline, want) {
+		t.Errorf("mainline: want %v, got %v", want, mainline)
+	}
+	if got := tree.Path(); len(got) != 3 || got[1] != tree.Root.Next {
+		t.Errorf("Path: got %v", got)
+	}
+}
+
+func TestGameMoveInvalid(t *testing.T) {
+	for _, m := range []Move{InitialMove, {}, NullMove} {
+		tree := NewGameTree()
+		if err := tree.Move(m); err == nil {
+			t.Errorf("%+v: want error", m)
+		}
+		if tree.Current != tree.Root || tree.Root.Next != nil {
+			t.Errorf("%+v: tree changed", m)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package shogi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// JSON棋譜フォーマット(JKF)。
+// https://github.com/na2hiro/json-kifu-format
+
+type jkf struct {
+	Header  map[string]string `json:"header"`
+	Initial *jkfInitial       `json:"initial,omitempty"`
+	Moves   []jkfMoveFormat   `json:"moves"`
+}
+
+type jkfInitial struct {
+	Preset string          `json:"preset"`
+	Data   *jkfStateFormat `json:"data,omitempty"`
+}
+
+type jkfStateFormat struct {
+	Color int               `json:"color"`
+	Board [9][9]jkfPiece    `json:"board"`
+	Hands [2]map[string]int `json:"hands"`
+}
+
+type jkfPiece struct {
+	Color *int   `json:"color,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+}
+
+type jkfMoveFormat struct {
+	Comments []string          `json:"comments,omitempty"`
+	Move     *jkfMove          `json:"move,omitempty"`
+	Time     *jkfTime          `json:"time,omitempty"`
+	Special  string            `json:"special,omitempty"`
+	Forks    [][]jkfMoveFormat `json:"forks,omitempty"`
+	// 以下はJKFの仕様にない拡張
+	Eval  *jkfEval `json:"eval,omitempty"`
+	Glyph string   `json:"glyph,omitempty"`
+}
+
+// 評価値は先手から見た値。読み筋はUSI形式。
+type jkfEval struct {
+	Score int      `json:"score"`
+	Mate  bool     `json:"mate,omitempty"`
+	Depth int      `json:"depth,omitempty"`
+	Pv    []string `json:"pv,omitempty"`
+}
+
+type jkfMove struct {
+	Color    int       `json:"color"`
+	From     *jkfPlace `json:"from,omitempty"`
+	To       *jkfPlace `json:"to"`
+	Piece    string    `json:"piece"`
+	Same     bool      `json:"same,omitempty"`
+	Promote  *bool     `json:"promote,omitempty"`
+	Capture  string    `json:"capture,omitempty"`
+	Relative string    `json:"relative,omitempty"`
+}
+
+type jkfPlace struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type jkfTime struct {
+	Now   jkfTimeFormat `json:"now"`
+	Total jkfTimeFormat `json:"total"`
+}
+
+type jkfTimeFormat struct {
+	H *int `json:"h,omitempty"`
+	M int  `json:"m"`
+	S int  `json:"s"`
+}
+
+var mapTerminalMoveJKF = map[string]Move{
+	"TORYO":        ToryoMove,
+	"CHUDAN":       ChudanMove,
+	"SENNICHITE":   SennichiteMove,
+	"JISHOGI":      JishogiMove,
+	"TSUMI":        TsumiMove,
+	"KACHI":        KachiMove,
+	"TIME_UP":      TimeUpMove,
+	"ILLEGAL_MOVE": IllegalMove,
+	"MAX_MOVES":    MaxMovesMove,
+	"HIKIWAKE":     HikiwakeMove,
+}
+
+var mapJKFTerminalMoveKind = map[MoveKind]string{
+	ToryoMoveKind:      "TORYO",
+	ChudanMoveKind:     "CHUDAN",
+	SennichiteMoveKind: "SENNICHITE",
+	JishogiMoveKind:    "JISHOGI",
+	TsumiMoveKind:      "TSUMI",
+	KachiMoveKind:      "KACHI",
+	TimeUpMoveKind:     "TIME_UP",
+	IllegalMoveKind:    "ILLEGAL_MOVE",
+	MaxMovesMoveKind:   "MAX_MOVES",
+	HikiwakeMoveKind:   "HIKIWAKE",
+}
+
+func NewGameTreeFromJKF(data []byte) (*GameTree, error) {
+	var j jkf
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("invalid jkf: %v", err)
+	}
+
+	p, err := j.Initial.position()
+	if err != nil {
+		return nil, err
+	}
+	tree := NewGameTreeFromPosition(p)
+	for key, value := range j.Header {
+		tree.Info.Set(key, value)
+	}
+	if tree.Info.Handicap == "" {
+		tree.Info.Handicap = handicapName(p)
+	}
+	if err := tree.readJKFMoves(j.Moves); err != nil {
+		return nil, err
+	}
+	tree.Current = tree.Root
+	return tree, nil
+}
+
+func (i *jkfInitial) position() (*Position, error) {
+	if i == nil {
+		return NewPosition(), nil
+	}
+	if i.Preset != "OTHER" {
+		for _, h := range handicaps {
+			if h.jkf == i.Preset {
+				return NewPositionFromSFEN(h.sfen)
+			}
+		}
+		return nil, fmt.Errorf("unknown preset: %s", i.Preset)
+	}
+	if i.Data == nil {
+		return nil, errors.New("initial data not found")
+	}
+
+	var board Board
+	for x := 0; x < 9; x++ {
+		for y := 0; y < 9; y++ {
+			piece := i.Data.Board[x][y]
+			if piece.Kind == "" {
+				continue
+			}
+			pt, err := NewPieceTypeFromCSA(piece.Kind)
+			if err != nil {
+				return nil, err
+			}
+			if piece.Color == nil {
+				return nil, fmt.Errorf("color of the piece is not specified: %d%d", x+1, y+1)
+			}
+			board[y][8-x] = NewPiece(pt, newColorFromJKF(*piece.Color))
+		}
+	}
+	hand := NewHand()
+	for c, hands := range i.Data.Hands {
+		for kind, n := range hands {
+			pt, err := NewPieceTypeFromCSA(kind)
+			if err != nil {
+				return nil, err
+			}
+			for ; n > 0; n-- {
+				if err := hand.Add(pt, newColorFromJKF(c)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return &Position{Board: &board, Turn: newColorFromJKF(i.Data.Color), Hand: hand, Ply: 0}, nil
+}
+
+func newColorFromJKF(c int) Color {
+	if c == 0 {
+		return Black
+	}
+	return White
+}
+
+func jkfColor(c Color) int {
+	if c == White {
+		return 1
+	}
+	return 0
+}
+
+// Currentからmovesを順に指す。分岐(forks)は変化として追加する。
+func (t *GameTree) readJKFMoves(moves []jkfMoveFormat) error {
+	for _, mf := range moves {
+		prev := t.Current
+		if err := t.readJKFMove(mf); err != nil {
+			return fmt.Errorf("%d-th move: %v", prev.Position.Ply+1, err)
+		}
+		current := t.Current
+		for _, fork := range mf.Forks {
+			t.Current = prev
+			if err := t.readJKFMoves(fork); err != nil {
+				return err
+			}
+		}
+		t.Current = current
+	}
+	return nil
+}
+
+func (t *GameTree) readJKFMove(mf jkfMoveFormat) error {
+	switch {
+	case mf.Special != "":
+		if err := t.readJKFSpecial(mf.Special); err != nil {
+			return err
+		}
+	case mf.Move != nil:
+		m, err := mf.Move.move(t.Current.Position)
+		if err != nil {
+			return err
+		}
+		if err := t.readMove(m); err != nil {
+			return err
+		}
+	}
+	// 指し手がなければ(開始局面など)コメントだけ
+	n := t.annotated()
+	n.Comments = append(n.Comments, mf.Comments...)
+	if mf.Time != nil {
+		n.SetTime(mf.Time.Now.duration())
+		n.TotalTime = mf.Time.Total.duration()
+	}
+	if mf.Eval != nil {
+		eval, err := mf.Eval.evaluation(n.Position)
+		if err != nil {
+			return err
+		}
+		n.Eval = eval
+	}
+	if mf.Glyph != "" {
+		g, err := NewGlyph(mf.Glyph)
+		if err != nil {
+			return err
+		}
+		n.Glyph = g
+	}
+	return nil
+}
+
+func (f jkfTimeFormat) duration() time.Duration {
+	d := time.Duration(f.M)*time.Minute + time.Duration(f.S)*time.Second
+	if f.H != nil {
+		d += time.Duration(*f.H) * time.Hour
+	}
+	return d
+}
+
+// 読み筋はpから指せる手でなければならない。
+func (e *jkfEval) evaluation(p *Position) (*Evaluation, error) {
+	eval := &Evaluation{Score: e.Score, IsMate: e.Mate, Depth: e.Depth}
+	p = p.Clone()
+	for _, usi := range e.Pv {
+		m, err := NewMoveFromUSI(usi)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pv: %v", err)
+		}
+		if err := p.Move(m); err != nil {
+			return nil, fmt.Errorf("invalid pv: %v", err)
+		}
+		eval.Pv = append(eval.Pv, m)
+	}
+	return eval, nil
+}
+
+func (t *GameTree) readJKFSpecial(special string) error {
+	// 手番に関わらず反則した側の負け
+	if special == "+ILLEGAL_ACTION" || special == "-ILLEGAL_ACTION" {
+		if err := t.readMove(IllegalMove); err != nil {
+			return err
+		}
+		t.Current.MoveData.Color, _ = NewColorFromCSA(special[0:1])
+		return nil
+	}
+	m, ok := mapTerminalMoveJKF[special]
+	if !ok {
+		return fmt.Errorf("unknown special: %s", special)
+	}
+	return t.readMove(m)
+}
+
+func (m *jkfMove) move(p *Position) (Move, error) {
+	if newColorFromJKF(m.Color) != p.Turn {
+		return NullMove, fmt.Errorf("not %v's turn", newColorFromJKF(m.Color))
+	}
+	if m.To == nil {
+		return NullMove, errors.New("to not found")
+	}
+	to, err := NewSquare(9-m.To.X, m.To.Y-1)
+	if err != nil {
+		return NullMove, err
+	}
+	pt, err := NewPieceTypeFromCSA(m.Piece)
+	if err != nil {
+		return NullMove, err
+	}
+	if m.From == nil {
+		return NewDropMove(pt, to), nil
+	}
+	from, err := NewSquare(9-m.From.X, m.From.Y-1)
+	if err != nil {
+		return NullMove, err
+	}
+	promote := m.Promote != nil && *m.Promote
+	return NewNormalMove(from, to, promote), nil
+}
+
+// JKFのJSONを出力する。
+func (t *GameTree) JKF() ([]byte, error) {
+	j := jkf{
+		Header:  t.Info.Header(),
+		Initial: t.Root.Position.jkfInitial(),
+		Moves:   append([]jkfMoveFormat{{Comments: t.Root.Comments}}, jkfMoves(t.Root.Next)...),
+	}
+	return json.Marshal(j)
+}
+
+func (p *Position) jkfInitial() *jkfInitial {
+	sfen := p.SFEN()
+	for _, h := range handicaps {
+		if h.sfen == sfen {
+			return &jkfInitial{Preset: h.jkf}
+		}
+	}
+
+	data := &jkfStateFormat{Color: jkfColor(p.Turn)}
+	for x := 0; x < 9; x++ {
+		for y := 0; y < 9; y++ {
+			piece := p.Board[y][8-x]
+			if piece == NO_PIECE {
+				continue
+			}
+			c := jkfColor(piece.Color())
+			data.Board[x][y] = jkfPiece{Color: &c, Kind: piece.PieceType().CSA()}
+		}
+	}
+	for i, c := range []Color{Black, White} {
+		data.Hands[i] = make(map[string]int)
+		for pt := FU; pt <= HI; pt++ {
+			n, _ := p.HandGet(pt, c)
+			data.Hands[i][pt.CSA()] = n
+		}
+	}
+	return &jkfInitial{Preset: "OTHER", Data: data}
+}
+
+// nからNextを辿った指し手の列。変化はforksにする。
+func jkfMoves(n *GameNode) []jkfMoveFormat {
+	moves := []jkfMoveFormat{}
+	for ; n != nil; n = n.Next {
+		mf := jkfMoveFormat{Comments: n.Comments}
+		m := n.MoveData
+		if m.IsTerminal() {
+			mf.Special = m.jkfSpecial(n.Position.Turn)
+		} else {
+			mf.Move = m.jkfMove()
+		}
+		if n.Time != 0 || n.TotalTime != 0 {
+			mf.Time = n.jkfTime()
+		}
+		if n.Eval != nil {
+			mf.Eval = &jkfEval{Score: n.Eval.Score, Mate: n.Eval.IsMate, Depth: n.Eval.Depth}
+			for _, m := range n.Eval.Pv {
+				mf.Eval.Pv = append(mf.Eval.Pv, m.USI())
+			}
+		}
+		mf.Glyph = n.Glyph.String()
+		// 変化の先頭ではforksを書かない
+		if n.Prev.Next == n {
+			for _, v := range n.Prev.Variations {
+				mf.Forks = append(mf.Forks, jkfMoves(v))
+			}
+		}
+		moves = append(moves, mf)
+	}
+	return moves
+}
+
+func (m MoveData) jkfSpecial(turn Color) string {
+	if c, ok := m.illegalAction(turn); ok {
+		return c.CSA() + "ILLEGAL_ACTION"
+	}
+	return mapJKFTerminalMoveKind[m.Kind]
+}
+
+func (m MoveData) jkfMove() *jkfMove {
+	move := &jkfMove{
+		Color: jkfColor(m.Color),
+		To:    &jkfPlace{X: 9 - m.To.File(), Y: m.To.Rank() + 1},
+	}
+	if m.IsDropMove() {
+		move.Piece = m.DropPieceType.CSA()
+		return move
+	}
+	move.From = &jkfPlace{X: 9 - m.From.File(), Y: m.From.Rank() + 1}
+	move.Piece = m.Piece.PieceType().CSA()
+	move.Same = m.Same
+	if m.Capture != NO_PIECE {
+		move.Capture = m.Capture.PieceType().CSA()
+	}
+	if CanPromote(m.Piece, m.From, m.To) {
+		promote := m.IsPromotion()
+		move.Promote = &promote
+	}
+	return move
+}
+
+func (n *GameNode) jkfTime() *jkfTime {
+	total := n.TotalTime
+	h := int(total / time.Hour)
+	return &jkfTime{
+		Now: jkfTimeFormat{
+			M: int(n.Time / time.Minute),
+			S: int(n.Time % time.Minute / time.Second),
+		},
+		Total: jkfTimeFormat{
+			H: &h,
+			M: int(total % time.Hour / time.Minute),
+			S: int(total % time.Minute / time.Second),
+		},
+	}
+}
@@ -0,0 +1,208 @@
+package shogi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGameTreeFromJKF(t *testing.T) {
+	data := `{
+  "header": {"先手": "A", "後手": "B"},
+  "initial": {"preset": "HIRATE"},
+  "moves": [
+    {"comments": ["start"]},
+    {"move": {"from": {"x": 7, "y": 7}, "to": {"x": 7, "y": 6}, "color": 0, "piece": "FU"}, "time": {"now": {"m": 1, "s": 2}, "total": {"h": 0, "m": 1, "s": 2}}},
+    {"move": {"from": {"x": 3, "y": 3}, "to": {"x": 3, "y": 4}, "color": 1, "piece": "FU"}, "comments": ["main"],
+     "forks": [[
+       {"move": {"from": {"x": 8, "y": 3}, "to": {"x": 8, "y": 4}, "color": 1, "piece": "FU"}, "comments": ["fork"]},
+       {"special": "TORYO"}
+     ]]},
+    {"move": {"from": {"x": 8, "y": 8}, "to": {"x": 2, "y": 2}, "color": 0, "piece": "KA", "promote": true, "capture": "KA"}},
+    {"move": {"same": true, "from": {"x": 3, "y": 1}, "to": {"x": 2, "y": 2}, "color": 1, "piece": "GI", "capture": "UM"}},
+    {"move": {"to": {"x": 5, "y": 5}, "color": 0, "piece": "KA"}},
+    {"special": "CHUDAN"}
+  ]
+}`
+	tree, err := NewGameTreeFromJKF([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tree.Info.Black != "A" || tree.Info.White != "B" || tree.Info.Handicap != "平手" {
+		t.Errorf("info: got %+v", tree.Info)
+	}
+	kifs := []string{}
+	for n := tree.Root; n != nil; n = n.Next {
+		kifs = append(kifs, n.MoveData.KIF())
+	}
+	wantKifs := []string{"開始局面", "７六歩(77)", "３四歩(33)", "２二角成(88)", "同銀(31)", "５五角打", "中断"}
+	if !reflect.DeepEqual(kifs, wantKifs) {
+		t.Errorf("kifs:\nwant %v\ngot %v", wantKifs, kifs)
+	}
+	if want := []string{"start"}; !reflect.DeepEqual(tree.Root.Comments, want) {
+		t.Errorf("root comments: want %v, got %v", want, tree.Root.Comments)
+	}
+	if want := time.Minute + 2*time.Second; tree.Root.Next.Time != want {
+		t.Errorf("time: want %v, got %v", want, tree.Root.Next.Time)
+	}
+
+	fork := tree.Root.Next.Variations
+	if len(fork) != 1 {
+		t.Fatalf("want 1 variation, got %d", len(fork))
+	}
+	if fork[0].MoveData.KIF() != "８四歩(83)" || fork[0].Next.MoveData.KIF() != "投了" {
+		t.Errorf("variation: got %v, %v", fork[0].MoveData.KIF(), fork[0].Next.MoveData.KIF())
+	}
+	if want := []string{"fork"}; !reflect.DeepEqual(fork[0].Comments, want) {
+		t.Errorf("variation comments: want %v, got %v", want, fork[0].Comments)
+	}
+
+	// 書き出して読み直しても同じになる
+	out, err := tree.JKF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree2, err := NewGameTreeFromJKF(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out2, err := tree2.JKF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var j1, j2 interface{}
+	json.Unmarshal(out, &j1)
+	json.Unmarshal(out2, &j2)
+	if !reflect.DeepEqual(j1, j2) {
+		t.Errorf("round trip:\nwant %s\ngot %s", out, out2)
+	}
+}
+
+func TestGameTreeJKFInitial(t *testing.T) {
+	tests := []struct {
+		sfen   string
+		preset string
+	}{
+		{"lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1", "HIRATE"},
+		{"lnsgkgsnl/9/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1", "2"},
+		{"6nkl/9/6+Pp1/9/9/9/9/9/9 b G2r2b3g4s3n3l16p 1", "OTHER"},
+	}
+	for _, test := range tests {
+		tree, err := NewGameTreeFromSFEN(test.sfen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := tree.JKF()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var j jkf
+		if err := json.Unmarshal(out, &j); err != nil {
+			t.Fatal(err)
+		}
+		if j.Initial.Preset != test.preset {
+			t.Errorf("%v: want preset %v, got %v", test.sfen, test.preset, j.Initial.Preset)
+		}
+		tree2, err := NewGameTreeFromJKF(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sfen := tree2.Root.Position.SFEN(); sfen != test.sfen {
+			t.Errorf("want %v, got %v", test.sfen, sfen)
+		}
+	}
+}
+
+func TestGameTreeJKFAnnotation(t *testing.T) {
+	data := `{
+  "header": {},
+  "moves": [
+    {},
+    {"move": {"from": {"x": 7, "y": 7}, "to": {"x": 7, "y": 6}, "color": 0, "piece": "FU"},
+     "time": {"now": {"m": 0, "s": 10}, "total": {"h": 0, "m": 1, "s": 10}},
+     "eval": {"score": 30, "depth": 12, "pv": ["3c3d", "2g2f"]}, "glyph": "!?"},
+    {"move": {"from": {"x": 3, "y": 3}, "to": {"x": 3, "y": 4}, "color": 1, "piece": "FU"},
+     "eval": {"score": -3, "mate": true}}
+  ]
+}`
+	tree, err := NewGameTreeFromJKF([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n1 := tree.Root.Next
+	n2 := n1.Next
+	if n1.Time != 10*time.Second || n1.TotalTime != 70*time.Second {
+		t.Errorf("time: got %v, %v", n1.Time, n1.TotalTime)
+	}
+	if n1.Eval.String() != "cp 30 depth 12 pv 3c3d 2g2f" || n1.Glyph != InterestingMove {
+		t.Errorf("annotation: got %v, %v", n1.Eval, n1.Glyph)
+	}
+	if n2.Eval.String() != "mate -3" || n2.Glyph != NoGlyph {
+		t.Errorf("annotation: got %v, %v", n2.Eval, n2.Glyph)
+	}
+
+	out, err := tree.JKF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree2, err := NewGameTreeFromJKF(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tree2.Root.Next.Eval, n1.Eval) || tree2.Root.Next.Glyph != n1.Glyph || tree2.Root.Next.TotalTime != n1.TotalTime {
+		t.Errorf("round trip: %s", out)
+	}
+}
+
+func TestGameTreeFromJKFKachi(t *testing.T) {
+	data := `{"header":{},"initial":{"preset":"HIRATE"},"moves":[{},{"move":{"from":{"x":7,"y":7},"to":{"x":7,"y":6},"color":0,"piece":"FU"}},{"special":"KACHI"}]}`
+	tree, err := NewGameTreeFromJKF([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Result{Winner: White, Reason: KachiMoveKind}
+	if got := tree.Result(); got != want {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestGameTreeFromJKFInvalidPv(t *testing.T) {
+	// 後手番の局面で先手の指し手を読み筋にしている
+	data := `{"header":{},"moves":[{},{"move":{"from":{"x":7,"y":7},"to":{"x":7,"y":6},"color":0,"piece":"FU"},"eval":{"score":0,"pv":["2g2f"]}}]}`
+	if _, err := NewGameTreeFromJKF([]byte(data)); err == nil {
+		t.Errorf("want error for illegal pv")
+	}
+}
+
+func TestGameTreeJKFMatingMoveAnnotation(t *testing.T) {
+	tree, err := NewGameTreeFromSFEN("4k4/9/4P4/9/9/9/9/9/4K4 b G 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := NewMoveFromUSI("G*5b")
+	if err := tree.Move(m); err != nil {
+		t.Fatal(err)
+	}
+	mate := tree.Root.Next
+	mate.SetTime(7 * time.Second)
+	mate.Comments = []string{"c"}
+	mate.Glyph = BrilliantMove
+
+	out, err := tree.JKF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := NewGameTreeFromJKF(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := read.Root.Next
+	if n.Time != 7*time.Second || !reflect.DeepEqual(n.Comments, []string{"c"}) || n.Glyph != BrilliantMove {
+		t.Errorf("move: got %v %v %v\n%s", n.Time, n.Comments, n.Glyph, out)
+	}
+	if n.Next == nil || n.Next.Time != 0 || len(n.Next.Comments) != 0 || n.Next.Glyph != NoGlyph {
+		t.Errorf("terminal: got %+v\n%s", n.Next, out)
+	}
+}
@@ -0,0 +1,173 @@
+package shogi
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KIFで出力するヘッダの順番。これ以外はExtraからキーの順に出力する。
+var kifHeaderKeys = []string{
+	"開始日時",
+	"終了日時",
+	"棋戦",
+	"場所",
+	"持ち時間",
+	"手合割",
+	"先手",
+	"後手",
+	"戦型",
+}
+
+// KIF形式で出力する。変化は本譜の後に"変化：N手"として続ける。
+func (t *GameTree) KIF() string {
+	var s strings.Builder
+
+	header := t.Info.Header()
+	delete(header, "結果")
+	if _, ok := header["手合割"]; !ok {
+		if name := handicapName(t.Root.Position); name != "" {
+			header["手合割"] = name
+		}
+	}
+	written := map[string]bool{}
+	for _, key := range kifHeaderKeys {
+		if value, ok := header[key]; ok {
+			s.WriteString(key + "：" + value + "\n")
+			written[key] = true
+		}
+	}
+	others := []string{}
+	for key := range header {
+		if !written[key] {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	for _, key := range others {
+		s.WriteString(key + "：" + header[key] + "\n")
+	}
+	// 手合割に当てはまらない局面は局面図で書く
+	if _, ok := header["手合割"]; !ok {
+		s.WriteString(t.Root.Position.BOD())
+	}
+
+	s.WriteString("手数----指手---------消費時間--\n")
+	writeKIFComments(&s, t.Root.Comments)
+	writeKIFMoves(&s, t.Root.Next)
+
+	if result := t.Result(); result.IsOver() {
+		last := t.Root
+		for last.Next != nil && !last.Next.MoveData.IsTerminal() {
+			last = last.Next
+		}
+		s.WriteString(fmt.Sprintf("まで%d手で%s\n", last.MoveData.Ply, kifResult(result)))
+	}
+
+	// 変化は本譜に近いものから書く
+	var writeVariations func(n *GameNode)
+	writeVariations = func(n *GameNode) {
+		for ; n != nil; n = n.Next {
+			for _, v := range n.Variations {
+				s.WriteString(fmt.Sprintf("\n変化：%d手\n", v.MoveData.Ply))
+				writeKIFMoves(&s, v)
+				writeVariations(v)
+			}
+		}
+	}
+	writeVariations(t.Root)
+
+	return s.String()
+}
+
+// nからNextを辿って指し手を書く。
+func writeKIFMoves(s *strings.Builder, n *GameNode) {
+	for ; n != nil; n = n.Next {
+		move := n.MoveData.KIF()
+		// 手番でない側の反則は手番側の反則勝ち
+		if n.MoveData.Kind == IllegalMoveKind && n.MoveData.Color != n.Position.Turn {
+			move = "反則勝ち"
+		}
+		s.WriteString(fmt.Sprintf("%4d %s%s\n", n.MoveData.Ply, padKIF(move, 14), kifTime(n)))
+		// 評価と記号はコメントとして書く
+		if n.Eval != nil {
+			s.WriteString("*" + n.Eval.kif(n.Position, n.MoveData.To) + "\n")
+		}
+		if n.Glyph != NoGlyph {
+			s.WriteString("*" + n.Glyph.String() + "\n")
+		}
+		writeKIFComments(s, n.Comments)
+	}
+}
+
+// 全角を2文字分として幅をwidthにする。
+func padKIF(s string, width int) string {
+	w := 0
+	for _, r := range s {
+		if r < 0x80 {
+			w++
+		} else {
+			w += 2
+		}
+	}
+	if w >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-w)
+}
+
+// "( 0:01/00:00:01)"の形式。
+func kifTime(n *GameNode) string {
+	d := n.Time / time.Second
+	total := n.TotalTime / time.Second
+	return fmt.Sprintf("(%2d:%02d/%02d:%02d:%02d)",
+		d/60, d%60, total/3600, total%3600/60, total%60)
+}
+
+var mapKIFTurnMark = map[Color]string{
+	Black: "▲",
+	White: "△",
+}
+
+// "評価値 30 深さ 12 読み筋 △３四歩(33) ▲２六歩(27)"の形式。詰みは"詰5"や"-詰5"と書く。
+func (e *Evaluation) kif(p *Position, before Square) string {
+	s := "評価値 " + strconv.Itoa(e.Score)
+	if e.IsMate {
+		s = "評価値 詰" + strconv.Itoa(e.Score)
+		if e.Score < 0 {
+			s = "評価値 -詰" + strconv.Itoa(-e.Score)
+		}
+	}
+	if e.Depth > 0 {
+		s += " 深さ " + strconv.Itoa(e.Depth)
+	}
+	if len(e.Pv) > 0 {
+		s += " 読み筋"
+	}
+	p = p.Clone()
+	for _, m := range e.Pv {
+		moveData := NewMoveData(m, p, before)
+		turn := p.Turn
+		if err := p.Move(m); err != nil {
+			break
+		}
+		s += " " + mapKIFTurnMark[turn] + moveData.KIF()
+		before = m.To
+	}
+	return s
+}
+
+func writeKIFComments(s *strings.Builder, comments []string) {
+	for _, c := range comments {
+		s.WriteString("*" + c + "\n")
+	}
+}
+
+func kifResult(r Result) string {
+	if r.Winner != NO_COLOR {
+		return mapKIFColor[r.Winner] + "の勝ち"
+	}
+	return mapKIFTerminalMoveKind[r.Reason]
+}
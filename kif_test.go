@@ -0,0 +1,74 @@
+package shogi
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGameTreeKIF(t *testing.T) {
+	tree := NewGameTree()
+	tree.Info.Black = "A"
+	tree.Info.White = "B"
+	tree.Root.Comments = []string{"開始"}
+	for i, usi := range []string{"7g7f", "3c3d", "8h2b+", "3a2b", "B*4e"} {
+		m, err := NewMoveFromUSI(usi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tree.Move(m); err != nil {
+			t.Fatal(err)
+		}
+		tree.SetTime(time.Duration(i+1) * time.Second)
+	}
+	tree.Current.Comments = []string{"角打ち"}
+	tree.Current.Glyph = DubiousMove
+	pv := []Move{}
+	for _, usi := range []string{"4a3a", "4e3d", "2b3c"} {
+		m, _ := NewMoveFromUSI(usi)
+		pv = append(pv, m)
+	}
+	tree.Current.Eval = &Evaluation{Score: 300, Depth: 10, Pv: pv}
+	if err := tree.Move(ToryoMove); err != nil {
+		t.Fatal(err)
+	}
+	tree.GotoNth(1)
+	m, _ := NewMoveFromUSI("8c8d")
+	if err := tree.Move(m); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `手合割：平手
+先手：A
+後手：B
+手数----指手---------消費時間--
+*開始
+   1 ７六歩(77)    ( 0:01/00:00:01)
+   2 ３四歩(33)    ( 0:02/00:00:02)
+   3 ２二角成(88)  ( 0:03/00:00:04)
+   4 同銀(31)      ( 0:04/00:00:06)
+   5 ４五角打      ( 0:05/00:00:09)
+*評価値 300 深さ 10 読み筋 △３一金(41) ▲３四角(45) △３三銀(22)
+*?!
+*角打ち
+   6 投了          ( 0:00/00:00:00)
+まで5手で先手の勝ち
+
+変化：2手
+   2 ８四歩(83)    ( 0:00/00:00:00)
+`
+	if got := tree.KIF(); got != want {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestGameTreeKIFPosition(t *testing.T) {
+	tree, err := NewGameTreeFromSFEN("8k/9/9/9/9/9/9/9/K6R1 b - 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kif := tree.KIF()
+	if !strings.Contains(kif, tree.Root.Position.BOD()) || strings.Contains(kif, "手合割") {
+		t.Errorf("want BOD:\n%s", kif)
+	}
+}
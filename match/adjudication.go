@@ -0,0 +1,101 @@
+package match
+
+import (
+	"github.com/eru1a/shogi-go"
+	"github.com/eru1a/shogi-go/engine"
+)
+
+// エンジンの評価値による終局の判定。
+// ResignScoreかResignMovesがゼロなら評価値による投了を、DrawMovesがゼロなら引き分けを判定しない。
+// ResignMovesとDrawMovesは両者の指し手を合わせた手数で、各手の評価値はその手を指したエンジンのもの。
+// 例えばResignMovesが4なら、それぞれのエンジンの2回ずつの評価値で判定する。
+type Adjudication struct {
+	// 両者の評価値がこの値(cp)以上の差で一方に傾いた状態が
+	// ResignMoves手続いたら不利な側を投了させる
+	ResignScore int
+	ResignMoves int
+	// 両者が同じ側の詰みを読んだら詰まされる側を投了させる
+	ResignOnMate bool
+	// DrawPly手以降、両者の評価値の絶対値がDrawScore(cp)以下の状態が
+	// DrawMoves手続いたら引き分けにする。DrawScoreがゼロなら評価値が0の時だけ、
+	// DrawPlyがゼロなら初手から判定する。
+	DrawPly   int
+	DrawScore int
+	DrawMoves int
+}
+
+// Currentの手番側で判定する。投了は手番側がするので、不利な側の手番になってから判定する。
+// 終局なら指し手と棋譜に残すコメントを返す。
+func (a *Adjudication) adjudicate(tree *shogi.GameTree) (shogi.Move, string, bool) {
+	// 新しい順の評価値。評価値のない指し手までさかのぼる。
+	evals := []*shogi.Evaluation{}
+	for n := tree.Current; n.Prev != nil && n.Eval != nil; n = n.Prev {
+		evals = append(evals, n.Eval)
+	}
+	// 手番側から見た評価値にする
+	sign := 1
+	if tree.Current.Position.Turn == shogi.White {
+		sign = -1
+	}
+
+	if a.ResignOnMate && len(evals) >= 2 {
+		mated := true
+		for _, e := range evals[:2] {
+			if !e.IsMate || sign*e.Score >= 0 {
+				mated = false
+			}
+		}
+		if mated {
+			return shogi.ToryoMove, "詰みの評価による投了", true
+		}
+	}
+
+	if a.ResignScore > 0 && a.ResignMoves > 0 && len(evals) >= a.ResignMoves {
+		losing := true
+		for _, e := range evals[:a.ResignMoves] {
+			if e.IsMate && sign*e.Score < 0 {
+				continue
+			}
+			if e.IsMate || sign*e.Score > -a.ResignScore {
+				losing = false
+			}
+		}
+		if losing {
+			return shogi.ToryoMove, "評価値による投了", true
+		}
+	}
+
+	if a.DrawMoves > 0 && tree.Current.Position.Ply >= a.DrawPly && len(evals) >= a.DrawMoves {
+		drawn := true
+		for _, e := range evals[:a.DrawMoves] {
+			if e.IsMate || e.Score > a.DrawScore || e.Score < -a.DrawScore {
+				drawn = false
+			}
+		}
+		if drawn {
+			return shogi.HikiwakeMove, "評価値による引き分け", true
+		}
+	}
+
+	return shogi.Move{}, "", false
+}
+
+// infoを先手から見た評価にする。評価値がなければnil。
+func newEvaluation(ev engine.Event, turn shogi.Color) *shogi.Evaluation {
+	info := ev.Info
+	pv, _ := ev.Pv()
+	e := &shogi.Evaluation{Depth: info.Depth, Pv: pv}
+	switch {
+	case info.IsMate:
+		e.Score = info.ScoreMate
+		e.IsMate = true
+	case info.IsCp:
+		e.Score = info.ScoreCp
+	default:
+		return nil
+	}
+	if turn == shogi.White {
+		e.Score = -e.Score
+	}
+	return e
+}
@@ -0,0 +1,88 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/eru1a/shogi-go"
+)
+
+func TestAdjudication(t *testing.T) {
+	usiMoves := []string{"7g7f", "3c3d", "2g2f", "8c8d"}
+	tests := []struct {
+		name  string
+		adj   Adjudication
+		evals []shogi.Evaluation
+		want  shogi.MoveKind
+	}{
+		{
+			name:  "評価値による投了",
+			adj:   Adjudication{ResignScore: 500, ResignMoves: 3},
+			evals: []shogi.Evaluation{{Score: 0}, {Score: -600}, {Score: -700}, {Score: -800}},
+			want:  shogi.ToryoMoveKind,
+		},
+		{
+			name:  "途中で評価値が戻ったら投了しない",
+			adj:   Adjudication{ResignScore: 500, ResignMoves: 3},
+			evals: []shogi.Evaluation{{Score: 0}, {Score: -600}, {Score: -400}, {Score: -800}},
+			want:  shogi.NullMoveKind,
+		},
+		{
+			name:  "不利な側の手番でなければ投了しない",
+			adj:   Adjudication{ResignScore: 500, ResignMoves: 3},
+			evals: []shogi.Evaluation{{Score: 0}, {Score: 600}, {Score: 700}, {Score: 800}},
+			want:  shogi.NullMoveKind,
+		},
+		{
+			name:  "詰みの評価による投了",
+			adj:   Adjudication{ResignOnMate: true},
+			evals: []shogi.Evaluation{{Score: 0}, {Score: 0}, {Score: -5, IsMate: true}, {Score: -4, IsMate: true}},
+			want:  shogi.ToryoMoveKind,
+		},
+		{
+			name:  "引き分け",
+			adj:   Adjudication{DrawPly: 4, DrawScore: 50, DrawMoves: 4},
+			evals: []shogi.Evaluation{{Score: 10}, {Score: -20}, {Score: 0}, {Score: 50}},
+			want:  shogi.HikiwakeMoveKind,
+		},
+		{
+			name:  "手数が足りなければ引き分けにしない",
+			adj:   Adjudication{DrawPly: 10, DrawScore: 50, DrawMoves: 4},
+			evals: []shogi.Evaluation{{Score: 10}, {Score: -20}, {Score: 0}, {Score: 50}},
+			want:  shogi.NullMoveKind,
+		},
+		{
+			name:  "DrawScoreがゼロなら評価値が0の時だけ引き分け",
+			adj:   Adjudication{DrawMoves: 4},
+			evals: []shogi.Evaluation{{Score: 0}, {Score: 0}, {Score: 0}, {Score: 0}},
+			want:  shogi.HikiwakeMoveKind,
+		},
+		{
+			name:  "DrawScoreがゼロで評価値が0でなければ引き分けにしない",
+			adj:   Adjudication{DrawMoves: 4},
+			evals: []shogi.Evaluation{{Score: 0}, {Score: 1}, {Score: 0}, {Score: 0}},
+			want:  shogi.NullMoveKind,
+		},
+	}
+
+	for _, test := range tests {
+		tree := shogi.NewGameTree()
+		for i, usi := range usiMoves {
+			m, err := shogi.NewMoveFromUSI(usi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tree.Move(m); err != nil {
+				t.Fatal(err)
+			}
+			e := test.evals[i]
+			tree.Current.Eval = &e
+		}
+		got := shogi.NullMoveKind
+		if m, _, ok := test.adj.adjudicate(tree); ok {
+			got = m.Kind
+		}
+		if got != test.want {
+			t.Errorf("%s: want %v, got %v", test.name, test.want, got)
+		}
+	}
+}
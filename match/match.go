@@ -0,0 +1,263 @@
+package match
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/eru1a/shogi-go"
+	"github.com/eru1a/shogi-go/clock"
+	"github.com/eru1a/shogi-go/engine"
+)
+
+// 2つのエンジンの対局。
+type Match struct {
+	Black *engine.Engine
+	White *engine.Engine
+	// 両者の持ち時間。ゼロなら時間切れはなく、goに持ち時間を付けない。
+	TimeControl shogi.TimeControl
+	// 秒読みを使い切ってからも時間切れにしない猶予(通信の遅延など)
+	Grace time.Duration
+	Rules shogi.Rules
+	// 開始局面
+	Position *shogi.Position
+	// 時刻の取得。nilならclock.RealTime。
+	TimeSource clock.TimeSource
+	// usiokの後にsetoptionで送るオプション
+	BlackOptions map[string]string
+	WhiteOptions map[string]string
+	// 評価値による終局の判定。nilなら判定しない。
+	Adjudication *Adjudication
+}
+
+func NewMatch(black, white *engine.Engine) *Match {
+	return &Match{
+		Black:    black,
+		White:    white,
+		Rules:    shogi.DefaultRules,
+		Position: shogi.NewPosition(),
+		Grace:    time.Second,
+	}
+}
+
+func (m *Match) engine(c shogi.Color) *engine.Engine {
+	if c == shogi.Black {
+		return m.Black
+	}
+	return m.White
+}
+
+func (m *Match) options(c shogi.Color) map[string]string {
+	if c == shogi.Black {
+		return m.BlackOptions
+	}
+	return m.WhiteOptions
+}
+
+func (m *Match) now() time.Time {
+	if m.TimeSource == nil {
+		return clock.RealTime.Now()
+	}
+	return m.TimeSource.Now()
+}
+
+func (m *Match) newClock() *clock.Clock {
+	return clock.NewClock(m.TimeControl, m.TimeControl, m.Grace, m.TimeSource)
+}
+
+// 1局指して棋譜を返す。
+// ctxがキャンセルされたら中断を記録した棋譜とctxのエラーを返す。
+func (m *Match) Run(ctx context.Context) (*shogi.GameTree, error) {
+	events := map[shogi.Color]<-chan engine.Event{}
+	for _, c := range []shogi.Color{shogi.Black, shogi.White} {
+		e := m.engine(c)
+		if err := prepare(ctx, e, m.options(c)); err != nil {
+			return nil, fmt.Errorf("match: %w", err)
+		}
+		ch, unsubscribe := e.Subscribe()
+		defer unsubscribe()
+		events[c] = ch
+	}
+	for _, c := range []shogi.Color{shogi.Black, shogi.White} {
+		if err := m.engine(c).NewGame(); err != nil {
+			return nil, fmt.Errorf("match: %w", err)
+		}
+	}
+
+	tree := shogi.NewGameTreeFromPosition(m.Position.Clone())
+	tree.Rules = m.Rules
+	tree.Info.Black = m.Black.Name()
+	tree.Info.White = m.White.Name()
+	tree.Info.TimeControl = m.TimeControl
+	tree.Info.StartTime = m.now()
+
+	clk := m.newClock()
+	if err := clk.Start(m.Position.Turn); err != nil {
+		return nil, fmt.Errorf("match: %w", err)
+	}
+
+	var runErr error
+	for !tree.Result().IsOver() {
+		turn := tree.Current.Position.Turn
+		e := m.engine(turn)
+		// 前の手の残りのイベントは捨てる
+		drain(events[turn])
+		if _, exited := e.Exited(); exited {
+			runErr = engine.ErrExited
+			break
+		}
+		if err := e.SetGameTree(tree); err != nil {
+			runErr = err
+			break
+		}
+		if _, err := e.Go(goParams(clk)); err != nil {
+			runErr = err
+			break
+		}
+
+		// bestmoveまでの最後の読み筋
+		var last *engine.Event
+		before := tree.Current
+	wait:
+		for {
+			select {
+			case ev := <-events[turn]:
+				switch ev.Type {
+				case engine.InfoEvent:
+					// 評価値のないinfoや上界・下界の評価値は読み筋として残さない
+					if i := ev.Info; i.MultiPv == 1 && (i.IsCp || i.IsMate) && !i.Upperbound && !i.Lowerbound {
+						info := ev
+						last = &info
+					}
+				case engine.BestMoveEvent:
+					if err := clk.Move(tree, ev.Move); err != nil && err != clock.ErrTimeUp {
+						runErr = err
+					}
+					if n := child(tree.Current, before); n != nil && last != nil && !n.MoveData.IsTerminal() {
+						n.Eval = newEvaluation(*last, turn)
+					}
+					break wait
+				case engine.ExitEvent:
+					clk.Stop()
+					runErr = engine.ErrExited
+					break wait
+				}
+			case <-clk.Flag():
+				// 思考を止めて遅れて来たbestmoveは捨てる
+				e.SendStop()
+				waitBestMove(events[turn])
+				if err := clk.Move(tree, shogi.TimeUpMove); err != nil && err != clock.ErrTimeUp {
+					runErr = err
+				}
+				break wait
+			case <-ctx.Done():
+				e.SendStop()
+				waitBestMove(events[turn])
+				clk.Stop()
+				tree.Move(shogi.ChudanMove)
+				runErr = ctx.Err()
+				break wait
+			}
+		}
+		if runErr != nil {
+			break
+		}
+
+		if m.Adjudication != nil && !tree.Result().IsOver() {
+			if move, comment, ok := m.Adjudication.adjudicate(tree); ok {
+				if err := clk.Move(tree, move); err != nil && err != clock.ErrTimeUp {
+					runErr = err
+					break
+				}
+				tree.Current.Comments = append(tree.Current.Comments, comment)
+			}
+		}
+	}
+	if runErr != nil && !tree.Result().IsOver() {
+		tree.Move(shogi.ChudanMove)
+	}
+
+	result := tree.Result()
+	tree.Info.EndTime = m.now()
+	tree.Info.Result = result.String()
+	for _, c := range []shogi.Color{shogi.Black, shogi.White} {
+		e := m.engine(c)
+		e.GameOver(gameOver(result, c))
+	}
+	if runErr != nil {
+		return tree, fmt.Errorf("match: %w", runErr)
+	}
+	return tree, nil
+}
+
+// usiokを待ってオプションを送り、readyokを待つ。
+func prepare(ctx context.Context, e *engine.Engine, options map[string]string) error {
+	if err := e.Init(ctx); err != nil {
+		return err
+	}
+	for name, value := range options {
+		if err := e.SetOption(name, value); err != nil {
+			return err
+		}
+	}
+	return e.WaitReady(ctx)
+}
+
+// bestmoveが来るまでのイベントを捨てる。
+func waitBestMove(events <-chan engine.Event) {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == engine.BestMoveEvent || ev.Type == engine.ExitEvent {
+				return
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// 溜まっているイベントを捨てる。
+func drain(events <-chan engine.Event) {
+	for {
+		select {
+		case <-events:
+		default:
+			return
+		}
+	}
+}
+
+// nからさかのぼってbeforeの子のノード。
+func child(n, before *shogi.GameNode) *shogi.GameNode {
+	for ; n != nil; n = n.Prev {
+		if n.Prev == before {
+			return n
+		}
+	}
+	return nil
+}
+
+// 時計からgoコマンドの引数を作る。加算があればbinc/wincを、なければ0でもbyoyomiを送る。
+// 持ち時間も秒読みも加算もなければ時間は送らない。
+func goParams(clk *clock.Clock) engine.GoParams {
+	return engine.GoParams{
+		BTime:   clk.Remaining(shogi.Black),
+		WTime:   clk.Remaining(shogi.White),
+		Byoyomi: clk.TimeControl(shogi.Black).Byoyomi,
+		BInc:    clk.TimeControl(shogi.Black).Increment,
+		WInc:    clk.TimeControl(shogi.White).Increment,
+	}
+}
+
+// gameoverコマンドの引数。
+func gameOver(r shogi.Result, c shogi.Color) engine.GameOverResult {
+	switch r.Winner {
+	case c:
+		return engine.Win
+	case c.Inv():
+		return engine.Lose
+	}
+	return engine.Draw
+}
@@ -0,0 +1,235 @@
+package match
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eru1a/shogi-go"
+	"github.com/eru1a/shogi-go/clock"
+	"github.com/eru1a/shogi-go/engine"
+	"github.com/eru1a/shogi-go/engine/enginetest"
+)
+
+// modeが"first"なら最初の合法手を、"resign"なら投了を、"illegal"なら反則手を指す。
+// "exit"ならgoで終了する。
+// "plus"と"minus"は手番側から見て評価値±1000の、"even"は評価値0のinfoを出してから最初の合法手を指す。
+// 評価値のinfoの後には評価値のないinfoと下界のinfoも出す。
+func newFakeEngine(t *testing.T, mode string) *engine.Engine {
+	t.Helper()
+	f := &enginetest.Fake{
+		Name:   "fake-" + mode,
+		Author: "test",
+		OnGo: func(position, command string) []enginetest.Line {
+			switch mode {
+			case "resign":
+				return []enginetest.Line{{Text: "bestmove resign"}}
+			case "illegal":
+				return []enginetest.Line{{Text: "bestmove 1a1a"}}
+			case "exit":
+				return []enginetest.Line{{Exit: true}}
+			}
+			tree, err := shogi.NewGameTreeFromUSI(position)
+			if err != nil {
+				t.Errorf("%s: %v", position, err)
+				return []enginetest.Line{{Text: "bestmove resign"}}
+			}
+			move := tree.Current.Position.LegalMoves()[0].USI()
+			switch mode {
+			case "plus", "minus", "even":
+				score := 1000
+				switch mode {
+				case "minus":
+					score = -1000
+				case "even":
+					score = 0
+				}
+				return []enginetest.Line{
+					{Text: fmt.Sprintf("info depth 1 score cp %d pv %s", score, move)},
+					{Text: "info nodes 1000 nps 10000"},
+					{Text: "info depth 2 score cp 3000 lowerbound"},
+					{Text: "bestmove " + move},
+				}
+			}
+			return []enginetest.Line{{Text: "bestmove " + move}}
+		},
+	}
+	return f.Engine()
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		black, white string
+		maxMoves     int
+		want         shogi.Result
+		plies        int
+	}{
+		{"first", "first", 10, shogi.Result{Winner: shogi.NO_COLOR, Reason: shogi.MaxMovesMoveKind}, 10},
+		{"first", "resign", 0, shogi.Result{Winner: shogi.Black, Reason: shogi.ToryoMoveKind}, 1},
+		{"illegal", "first", 0, shogi.Result{Winner: shogi.White, Reason: shogi.IllegalMoveKind}, 0},
+	}
+
+	for _, test := range tests {
+		black := newFakeEngine(t, test.black)
+		white := newFakeEngine(t, test.white)
+
+		m := NewMatch(black, white)
+		m.TimeControl = shogi.TimeControl{Main: time.Minute, Byoyomi: 10 * time.Second}
+		m.Rules.MaxMoves = test.maxMoves
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		tree, err := m.Run(ctx)
+		black.Close(ctx)
+		white.Close(ctx)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := tree.Result(); got != test.want {
+			t.Errorf("%s vs %s: want %v, got %v", test.black, test.white, test.want, got)
+		}
+		if tree.Info.Black != "fake-"+test.black || tree.Info.White != "fake-"+test.white {
+			t.Errorf("names: got %v, %v", tree.Info.Black, tree.Info.White)
+		}
+		plies := 0
+		for n := tree.Root.Next; n != nil && !n.MoveData.IsTerminal(); n = n.Next {
+			plies++
+		}
+		if plies != test.plies {
+			t.Errorf("%s vs %s: want %d plies, got %d", test.black, test.white, test.plies, plies)
+		}
+	}
+}
+
+func TestGoParams(t *testing.T) {
+	tests := []struct {
+		tc   shogi.TimeControl
+		want string
+	}{
+		{shogi.TimeControl{Main: time.Minute, Byoyomi: 10 * time.Second}, "go btime 60000 wtime 60000 byoyomi 10000"},
+		{shogi.TimeControl{Main: time.Minute, Increment: 2 * time.Second}, "go btime 60000 wtime 60000 binc 2000 winc 2000"},
+		{shogi.TimeControl{Main: time.Minute}, "go btime 60000 wtime 60000 byoyomi 0"},
+		{shogi.TimeControl{}, "go"},
+	}
+	for _, test := range tests {
+		m := NewMatch(nil, nil)
+		m.TimeControl = test.tc
+		clk := m.newClock()
+		if got := goParams(clk).USI(); got != test.want {
+			t.Errorf("want %v, got %v", test.want, got)
+		}
+	}
+}
+
+func TestMatchAdjudication(t *testing.T) {
+	black := newFakeEngine(t, "plus")
+	white := newFakeEngine(t, "minus")
+	defer black.Close(context.Background())
+	defer white.Close(context.Background())
+
+	m := NewMatch(black, white)
+	m.TimeControl = shogi.TimeControl{Main: time.Minute}
+	m.Adjudication = &Adjudication{ResignScore: 500, ResignMoves: 3}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tree, err := m.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := shogi.Result{Winner: shogi.Black, Reason: shogi.ToryoMoveKind}
+	if got := tree.Result(); got != want {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	// 3手目で条件を満たし、後手の手番の4手目で投了する
+	if tree.Current.MoveData.Ply != 4 || len(tree.Current.Comments) != 1 {
+		t.Errorf("got ply %d, comments %v", tree.Current.MoveData.Ply, tree.Current.Comments)
+	}
+	if e := tree.Root.Next.Next.Eval; e == nil || e.Score != 1000 || len(e.Pv) != 1 {
+		t.Errorf("eval: got %v", e)
+	}
+}
+
+func TestMatchAdjudicationDraw(t *testing.T) {
+	black := newFakeEngine(t, "even")
+	white := newFakeEngine(t, "even")
+	defer black.Close(context.Background())
+	defer white.Close(context.Background())
+
+	m := NewMatch(black, white)
+	m.TimeControl = shogi.TimeControl{Main: time.Minute}
+	m.Adjudication = &Adjudication{DrawPly: 4, DrawScore: 50, DrawMoves: 4}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tree, err := m.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := shogi.Result{Winner: shogi.NO_COLOR, Reason: shogi.HikiwakeMoveKind}
+	if got := tree.Result(); got != want {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	// 最大手数とは区別して書き出す
+	csa := tree.CSA()
+	if !strings.Contains(csa, "%HIKIWAKE") {
+		t.Errorf("csa: got %v", csa)
+	}
+	if kif := tree.KIF(); !strings.Contains(kif, "まで4手で引き分け") {
+		t.Errorf("kif: got %v", kif)
+	}
+	read, err := shogi.NewGameTreeFromCSA(csa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := read.Result(); got != want {
+		t.Errorf("read csa: want %v, got %v", want, got)
+	}
+}
+
+func TestMatchTimeSource(t *testing.T) {
+	black := newFakeEngine(t, "first")
+	white := newFakeEngine(t, "resign")
+	defer black.Close(context.Background())
+	defer white.Close(context.Background())
+
+	start := time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC)
+	m := NewMatch(black, white)
+	m.TimeControl = shogi.TimeControl{Main: time.Minute}
+	m.TimeSource = clock.NewFakeTime(start)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tree, err := m.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 開始と終了の時刻も時計と同じ時刻から取る
+	if !tree.Info.StartTime.Equal(start) || !tree.Info.EndTime.Equal(start) {
+		t.Errorf("want %v, got %v - %v", start, tree.Info.StartTime, tree.Info.EndTime)
+	}
+}
+
+func TestMatchEngineExit(t *testing.T) {
+	black := newFakeEngine(t, "first")
+	white := newFakeEngine(t, "exit")
+	defer black.Close(context.Background())
+	defer white.Close(context.Background())
+
+	m := NewMatch(black, white)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tree, err := m.Run(ctx)
+	if !errors.Is(err, engine.ErrExited) {
+		t.Fatalf("want %v, got %v", engine.ErrExited, err)
+	}
+	if got := tree.Result().Reason; got != shogi.ChudanMoveKind {
+		t.Errorf("want chudan, got %v", got)
+	}
+}
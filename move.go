@@ -12,6 +12,16 @@ const (
 	NormalMoveKind
 	DropMoveKind
 	ToryoMoveKind
+	ChudanMoveKind
+	SennichiteMoveKind
+	JishogiMoveKind
+	TsumiMoveKind
+	KachiMoveKind
+	TimeUpMoveKind
+	IllegalMoveKind
+	OuteSennichiteMoveKind
+	MaxMovesMoveKind
+	HikiwakeMoveKind
 )
 
 type Move struct {
@@ -26,6 +36,24 @@ var NullMove = Move{Kind: NullMoveKind, From: NullSquare, To: NullSquare}
 var InitialMove = Move{Kind: InitialMoveKind, From: NullSquare, To: NullSquare}
 var ToryoMove = Move{Kind: ToryoMoveKind, From: NullSquare, To: NullSquare}
 
+// 対局の終了を表す特殊な指し手。
+// 投了以外は棋譜形式から読み込むときや終局を判定したときに使う。
+var (
+	ChudanMove     = Move{Kind: ChudanMoveKind, From: NullSquare, To: NullSquare}
+	SennichiteMove = Move{Kind: SennichiteMoveKind, From: NullSquare, To: NullSquare}
+	JishogiMove    = Move{Kind: JishogiMoveKind, From: NullSquare, To: NullSquare}
+	TsumiMove      = Move{Kind: TsumiMoveKind, From: NullSquare, To: NullSquare}
+	KachiMove      = Move{Kind: KachiMoveKind, From: NullSquare, To: NullSquare}
+	TimeUpMove     = Move{Kind: TimeUpMoveKind, From: NullSquare, To: NullSquare}
+	IllegalMove    = Move{Kind: IllegalMoveKind, From: NullSquare, To: NullSquare}
+	// 連続王手の千日手
+	OuteSennichiteMove = Move{Kind: OuteSennichiteMoveKind, From: NullSquare, To: NullSquare}
+	// 最大手数に達した
+	MaxMovesMove = Move{Kind: MaxMovesMoveKind, From: NullSquare, To: NullSquare}
+	// 評価値などによる引き分けの判定
+	HikiwakeMove = Move{Kind: HikiwakeMoveKind, From: NullSquare, To: NullSquare}
+)
+
 func NewNormalMove(from, to Square, promotion bool) Move {
 	return Move{
 		Kind:          NormalMoveKind,
@@ -75,6 +103,15 @@ func (m Move) IsSpecialMove() bool {
 	}
 }
 
+// 投了や千日手など対局の終了を表す指し手ならtrue。
+func (m Move) IsTerminal() bool {
+	switch m.Kind {
+	case NullMoveKind, InitialMoveKind, NormalMoveKind, DropMoveKind:
+		return false
+	}
+	return true
+}
+
 func (m Move) IsPromotion() bool {
 	return m.Promotion
 }
@@ -15,20 +15,52 @@ type MoveData struct {
 }
 
 var InitialMoveData = MoveData{Move: InitialMove}
-var ToryoMoveData = MoveData{Move: ToryoMove}
+
+var mapCSATerminalMoveKind = map[MoveKind]string{
+	ToryoMoveKind:      "%TORYO",
+	ChudanMoveKind:     "%CHUDAN",
+	SennichiteMoveKind: "%SENNICHITE",
+	JishogiMoveKind:    "%JISHOGI",
+	TsumiMoveKind:      "%TSUMI",
+	KachiMoveKind:      "%KACHI",
+	TimeUpMoveKind:     "%TIME_UP",
+	IllegalMoveKind:    "%ILLEGAL_MOVE",
+	MaxMovesMoveKind:   "%MAX_MOVES",
+	HikiwakeMoveKind:   "%HIKIWAKE",
+}
+
+var mapKIFTerminalMoveKind = map[MoveKind]string{
+	ToryoMoveKind:      "投了",
+	ChudanMoveKind:     "中断",
+	SennichiteMoveKind: "千日手",
+	JishogiMoveKind:    "持将棋",
+	TsumiMoveKind:      "詰み",
+	KachiMoveKind:      "入玉勝ち",
+	TimeUpMoveKind:     "切れ負け",
+	IllegalMoveKind:    "反則負け",
+	// 連続王手をかけられた手番側の勝ち
+	OuteSennichiteMoveKind: "反則勝ち",
+	MaxMovesMoveKind:       "最大手数",
+	HikiwakeMoveKind:       "引き分け",
+}
 
 func NewMoveData(m Move, p *Position, before Square) MoveData {
 	if m.IsInitialMove() {
 		return InitialMoveData
 	}
-	if m.IsToryo() {
-		return ToryoMoveData
+	if m.IsTerminal() {
+		// 手番側が投了などをしたものとする
+		return MoveData{
+			Move:  m,
+			Color: p.Turn,
+			Ply:   p.Ply + 1,
+		}
 	}
 	if m.IsDropMove() {
 		return MoveData{
 			Move:  m,
 			Color: p.Turn,
-			Ply:   p.Ply,
+			Ply:   p.Ply + 1,
 		}
 	}
 
@@ -52,8 +84,8 @@ func (m MoveData) KIF() string {
 	if m.IsInitialMove() {
 		return "開始局面"
 	}
-	if m.IsToryo() {
-		return "投了"
+	if m.IsTerminal() {
+		return mapKIFTerminalMoveKind[m.Kind]
 	}
 	to := m.To.KIF()
 	if m.IsDropMove() {
@@ -71,3 +103,22 @@ func (m MoveData) KIF() string {
 	}
 	return fmt.Sprintf("%v%v%v(%v)", to, p, nari, from)
 }
+
+// "+7776FU"のような形式。駒は移動後のもの。
+func (m MoveData) CSA() string {
+	if m.IsTerminal() {
+		// 連続王手の千日手は王手をかけ続けた側の反則
+		if m.Kind == OuteSennichiteMoveKind {
+			return "%" + m.Color.Inv().CSA() + "ILLEGAL_ACTION"
+		}
+		return mapCSATerminalMoveKind[m.Kind]
+	}
+	if m.IsDropMove() {
+		return m.Color.CSA() + m.From.CSA() + m.To.CSA() + m.DropPieceType.CSA()
+	}
+	pt := m.Piece.PieceType()
+	if m.IsPromotion() {
+		pt = pt.Promote()
+	}
+	return m.Color.CSA() + m.From.CSA() + m.To.CSA() + pt.CSA()
+}
@@ -19,7 +19,7 @@ func TestMoveDataKIF(t *testing.T) {
 		},
 		{
 			kif:      "投了",
-			moveData: ToryoMoveData,
+			moveData: NewMoveData(ToryoMove, NewPosition(), NullSquare),
 		},
 		{
 			kif:      "７六歩(77)",
@@ -46,3 +46,31 @@ func TestMoveDataKIF(t *testing.T) {
 		}
 	}
 }
+
+func TestMoveDataCSA(t *testing.T) {
+	tests := []struct {
+		csa      string
+		moveData MoveData
+	}{
+		{"%TORYO", NewMoveData(ToryoMove, NewPosition(), NullSquare)},
+		{"%HIKIWAKE", MoveData{Move: HikiwakeMove, Color: Black}},
+		// 後手が連続王手をかけ続けた
+		{"%-ILLEGAL_ACTION", MoveData{Move: OuteSennichiteMove, Color: Black}},
+		{"+7776FU", newMoveData("7g7f", "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1", NullSquare)},
+	}
+
+	for _, test := range tests {
+		if csa := test.moveData.CSA(); csa != test.csa {
+			t.Errorf("%v.CSA(): want %v, got %v", test.moveData, test.csa, csa)
+		}
+	}
+}
+
+func TestMoveDataPly(t *testing.T) {
+	sfen := "lnsgkg1nl/1r5s1/pppppp1pp/6p2/9/2P6/PP1PPPPPP/7R1/LNSGKGSNL b Bb 5"
+	for _, usi := range []string{"B*5e", "2h2g"} {
+		if md := newMoveData(usi, sfen, NullSquare); md.Ply != 5 {
+			t.Errorf("%v: want ply 5, got %d", usi, md.Ply)
+		}
+	}
+}
@@ -77,6 +77,59 @@ var (
 		UM: "馬",
 		RY: "龍",
 	}
+	mapPieceTypeKIF = map[string]PieceType{
+		"歩":  FU,
+		"香":  KY,
+		"桂":  KE,
+		"銀":  GI,
+		"金":  KI,
+		"角":  KA,
+		"飛":  HI,
+		"王":  OU,
+		"玉":  OU,
+		"と":  TO,
+		"杏":  NY,
+		"成香": NY,
+		"圭":  NK,
+		"成桂": NK,
+		"全":  NG,
+		"成銀": NG,
+		"馬":  UM,
+		"龍":  RY,
+		"竜":  RY,
+	}
+	mapPieceTypeCSA = map[string]PieceType{
+		"FU": FU,
+		"KY": KY,
+		"KE": KE,
+		"GI": GI,
+		"KI": KI,
+		"KA": KA,
+		"HI": HI,
+		"OU": OU,
+		"TO": TO,
+		"NY": NY,
+		"NK": NK,
+		"NG": NG,
+		"UM": UM,
+		"RY": RY,
+	}
+	mapCSAPieceType = map[PieceType]string{
+		FU: "FU",
+		KY: "KY",
+		KE: "KE",
+		GI: "GI",
+		KI: "KI",
+		KA: "KA",
+		HI: "HI",
+		OU: "OU",
+		TO: "TO",
+		NY: "NY",
+		NK: "NK",
+		NG: "NG",
+		UM: "UM",
+		RY: "RY",
+	}
 )
 
 func NewPieceTypeFromUSI(usi string) (PieceType, error) {
@@ -103,6 +156,30 @@ func (pt PieceType) KIF() string {
 	return kif
 }
 
+func NewPieceTypeFromKIF(kif string) (PieceType, error) {
+	pt, ok := mapPieceTypeKIF[kif]
+	if !ok {
+		return NO_PIECE_TYPE, fmt.Errorf("invalid kif: %s", kif)
+	}
+	return pt, nil
+}
+
+func NewPieceTypeFromCSA(csa string) (PieceType, error) {
+	pt, ok := mapPieceTypeCSA[csa]
+	if !ok {
+		return NO_PIECE_TYPE, fmt.Errorf("invalid csa: %s", csa)
+	}
+	return pt, nil
+}
+
+func (pt PieceType) CSA() string {
+	csa, ok := mapCSAPieceType[pt]
+	if !ok {
+		return " * "
+	}
+	return csa
+}
+
 func (pt PieceType) Promote() PieceType {
 	switch pt {
 	case NO_PIECE_TYPE, KI, OU:
@@ -259,6 +336,29 @@ func (p Piece) USI() string {
 	return usi
 }
 
+// "+FU"や"-KY"のような形式。
+func NewPieceFromCSA(csa string) (Piece, error) {
+	if len(csa) != 3 {
+		return NO_PIECE, fmt.Errorf("length should be 3: %s", csa)
+	}
+	c, err := NewColorFromCSA(csa[0:1])
+	if err != nil {
+		return NO_PIECE, err
+	}
+	pt, err := NewPieceTypeFromCSA(csa[1:])
+	if err != nil {
+		return NO_PIECE, err
+	}
+	return NewPiece(pt, c), nil
+}
+
+func (p Piece) CSA() string {
+	if p == NO_PIECE {
+		return " * "
+	}
+	return p.Color().CSA() + p.PieceType().CSA()
+}
+
 func (p Piece) Promote() Piece {
 	if p == NO_PIECE {
 		return NO_PIECE
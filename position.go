@@ -6,8 +6,6 @@ import (
 	"strings"
 )
 
-// TODO: 千日手
-
 type Position struct {
 	Board *Board
 	Turn  Color
@@ -80,14 +78,9 @@ func (p *Position) HandGet(pt PieceType, c Color) (int, error) {
 	return p.Hand.Get(pt, c)
 }
 
+// DefaultRulesで合法手ならmを指す。
 func (p *Position) Move(m Move) error {
-	if p.IsLegalMove(m) {
-		if err := p.move(m); err != nil {
-			return err
-		}
-		return nil
-	}
-	return fmt.Errorf("illegal move: %v, %v", p, m)
+	return DefaultRules.Move(p, m)
 }
 
 func (p *Position) move(m Move) error {
@@ -150,17 +143,13 @@ func (p *Position) String() string {
 	return p.SFEN()
 }
 
+// DefaultRulesで合法手ならtrue。
 func (p *Position) IsLegalMove(m Move) bool {
-	for _, move := range p.LegalMoves() {
-		if m == move {
-			return true
-		}
-	}
-	return false
+	return DefaultRules.IsLegalMove(p, m)
 }
 
-// 王手放置、打ち歩詰め
-func (p *Position) isForbiddenMove(m Move) bool {
+// 王手放置、打ち歩詰め(ルールで禁止されている場合)
+func (p *Position) isForbiddenMove(m Move, r *Rules) bool {
 	clone := p.Clone()
 	clone.move(m)
 	// 動かした局面でこちら側が王手なら非合法手
@@ -168,21 +157,15 @@ func (p *Position) isForbiddenMove(m Move) bool {
 		return false
 	}
 	// 打ち歩詰め
-	if m.IsDropMove() && m.DropPieceType == FU && clone.IsInCheck() && clone.IsCheckmate() {
+	if r.ForbidPawnDropMate && m.IsDropMove() && m.DropPieceType == FU && clone.IsInCheck() && clone.IsCheckmate() {
 		return false
 	}
 	return true
 }
 
+// DefaultRulesでの合法手。
 func (p *Position) LegalMoves() []Move {
-	pseudo := p.pseudoLegalMoves(p.Turn)
-	moves := []Move{}
-	for _, move := range pseudo {
-		if p.isForbiddenMove(move) {
-			moves = append(moves, move)
-		}
-	}
-	return moves
+	return DefaultRules.LegalMoves(p)
 }
 
 type offsets [][2]int
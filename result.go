@@ -0,0 +1,78 @@
+package shogi
+
+// 対局結果。
+type Result struct {
+	// 勝った側。引き分けや中断、対局中ならNO_COLOR。
+	Winner Color
+	// 終局の理由。対局中ならNullMoveKind。
+	Reason MoveKind
+}
+
+var mapKIFColor = map[Color]string{
+	Black: "先手",
+	White: "後手",
+}
+
+func (r Result) IsOver() bool {
+	return r.Reason != NullMoveKind
+}
+
+func (r Result) IsDraw() bool {
+	switch r.Reason {
+	case SennichiteMoveKind, JishogiMoveKind, MaxMovesMoveKind, HikiwakeMoveKind:
+		return true
+	}
+	return false
+}
+
+func (r Result) String() string {
+	switch {
+	case !r.IsOver():
+		return "対局中"
+	case r.Winner != NO_COLOR:
+		return mapKIFColor[r.Winner] + "の勝ち(" + mapKIFTerminalMoveKind[r.Reason] + ")"
+	case r.Reason == HikiwakeMoveKind:
+		return "引き分け"
+	case r.IsDraw():
+		return "引き分け(" + mapKIFTerminalMoveKind[r.Reason] + ")"
+	}
+	return mapKIFTerminalMoveKind[r.Reason]
+}
+
+// 終局の指し手からの結果。MoveDataのColorは終局時の手番側で、
+// 反則はColorが反則した側、トライルールの入玉勝ちと連続王手の千日手は勝った側になる。
+func (m MoveData) result() Result {
+	switch m.Kind {
+	case ToryoMoveKind, TsumiMoveKind, TimeUpMoveKind, IllegalMoveKind:
+		return Result{Winner: m.Color.Inv(), Reason: m.Kind}
+	case KachiMoveKind, OuteSennichiteMoveKind:
+		return Result{Winner: m.Color, Reason: m.Kind}
+	case SennichiteMoveKind, JishogiMoveKind, MaxMovesMoveKind, HikiwakeMoveKind, ChudanMoveKind:
+		return Result{Winner: NO_COLOR, Reason: m.Kind}
+	}
+	return Result{Winner: NO_COLOR, Reason: NullMoveKind}
+}
+
+// 手番に関わらない反則(CSAやJKFのILLEGAL_ACTION)として書くべきなら反則した側を返す。
+func (m MoveData) illegalAction(turn Color) (Color, bool) {
+	switch {
+	case m.Kind == IllegalMoveKind && m.Color != turn:
+		return m.Color, true
+	case m.Kind == OuteSennichiteMoveKind:
+		// 王手をかけ続けた側の反則
+		return m.Color.Inv(), true
+	}
+	return NO_COLOR, false
+}
+
+// 本譜の結果。
+func (t *GameTree) Result() Result {
+	n := t.Root
+	for n.Next != nil {
+		n = n.Next
+	}
+	if n.MoveData.IsTerminal() {
+		return n.MoveData.result()
+	}
+	return Result{Winner: NO_COLOR, Reason: NullMoveKind}
+}
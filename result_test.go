@@ -0,0 +1,72 @@
+package shogi
+
+import "testing"
+
+func TestMoveDataResult(t *testing.T) {
+	tests := []struct {
+		moveData MoveData
+		result   Result
+		s        string
+	}{
+		{MoveData{Move: ToryoMove, Color: White}, Result{Black, ToryoMoveKind}, "先手の勝ち(投了)"},
+		{MoveData{Move: TsumiMove, Color: Black}, Result{White, TsumiMoveKind}, "後手の勝ち(詰み)"},
+		{MoveData{Move: TimeUpMove, Color: Black}, Result{White, TimeUpMoveKind}, "後手の勝ち(切れ負け)"},
+		{MoveData{Move: IllegalMove, Color: White}, Result{Black, IllegalMoveKind}, "先手の勝ち(反則負け)"},
+		{MoveData{Move: KachiMove, Color: Black}, Result{Black, KachiMoveKind}, "先手の勝ち(入玉勝ち)"},
+		{MoveData{Move: OuteSennichiteMove, Color: White}, Result{White, OuteSennichiteMoveKind}, "後手の勝ち(反則勝ち)"},
+		{MoveData{Move: SennichiteMove, Color: Black}, Result{NO_COLOR, SennichiteMoveKind}, "引き分け(千日手)"},
+		{MoveData{Move: JishogiMove, Color: Black}, Result{NO_COLOR, JishogiMoveKind}, "引き分け(持将棋)"},
+		{MoveData{Move: MaxMovesMove, Color: Black}, Result{NO_COLOR, MaxMovesMoveKind}, "引き分け(最大手数)"},
+		{MoveData{Move: HikiwakeMove, Color: Black}, Result{NO_COLOR, HikiwakeMoveKind}, "引き分け"},
+		{MoveData{Move: ChudanMove, Color: Black}, Result{NO_COLOR, ChudanMoveKind}, "中断"},
+		{InitialMoveData, Result{NO_COLOR, NullMoveKind}, "対局中"},
+	}
+	for _, test := range tests {
+		r := test.moveData.result()
+		if r != test.result {
+			t.Errorf("%v: want %v, got %v", test.moveData.KIF(), test.result, r)
+		}
+		if r.String() != test.s {
+			t.Errorf("%v: want %v, got %v", test.moveData.KIF(), test.s, r.String())
+		}
+	}
+}
+
+func TestGameTreeResult(t *testing.T) {
+	tree := NewGameTree()
+	if r := tree.Result(); r.IsOver() {
+		t.Errorf("want not over, got %v", r)
+	}
+	m, _ := NewMoveFromUSI("7g7f")
+	tree.Move(m)
+	tree.Move(ToryoMove)
+	if r := tree.Result(); r != (Result{Black, ToryoMoveKind}) {
+		t.Errorf("want black wins by toryo, got %v", r)
+	}
+}
+
+func TestGameTreeCheckmate(t *testing.T) {
+	tree, _ := NewGameTreeFromSFEN("8k/9/8P/9/9/9/9/9/9 b G 1")
+	m, _ := NewMoveFromUSI("G*1b")
+	if err := tree.Move(m); err != nil {
+		t.Fatal(err)
+	}
+	// 詰みが自動で追加される
+	if !tree.Current.MoveData.IsTerminal() || tree.Current.Prev.MoveData.Move != m {
+		t.Fatalf("want tsumi after %v, got %v", m, tree.Current.MoveData.KIF())
+	}
+	if r := tree.Result(); r != (Result{Black, TsumiMoveKind}) {
+		t.Errorf("want black wins by tsumi, got %v", r)
+	}
+	if err := tree.Move(m); err == nil {
+		t.Errorf("want error after the game is over")
+	}
+
+	// 棋譜に書かれた投了で置き換えられる
+	if err := tree.Move(ToryoMove); err != nil {
+		t.Fatal(err)
+	}
+	if r := tree.Result(); r != (Result{Black, ToryoMoveKind}) {
+		t.Errorf("want black wins by toryo, got %v", r)
+	}
+}
@@ -0,0 +1,206 @@
+package shogi
+
+import "fmt"
+
+// 入玉のルール。
+type ImpasseRule uint8
+
+const (
+	// 入玉宣言を認めない
+	NoImpasse ImpasseRule = iota
+	// 24点法。31点以上で勝ち、24点以上で引き分け。
+	Impasse24
+	// 27点法。先手28点以上、後手27点以上で勝ち。
+	Impasse27
+	// トライルール。玉が相手の玉の初期位置に入ったら勝ち。
+	TryRule
+)
+
+// 対局のルール。
+type Rules struct {
+	// 最大手数。この手数に達したら引き分け。0なら制限なし。
+	MaxMoves int
+	Impasse  ImpasseRule
+	// 連続王手の千日手を王手をかけた側の負けにするか
+	PerpetualCheckLoses bool
+	// 打ち歩詰めを禁止するか
+	ForbidPawnDropMate bool
+}
+
+// 一般的なルール。
+var DefaultRules = Rules{
+	MaxMoves:            0,
+	Impasse:             Impasse27,
+	PerpetualCheckLoses: true,
+	ForbidPawnDropMate:  true,
+}
+
+func (r *Rules) LegalMoves(p *Position) []Move {
+	pseudo := p.pseudoLegalMoves(p.Turn)
+	moves := []Move{}
+	for _, move := range pseudo {
+		if p.isForbiddenMove(move, r) {
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
+func (r *Rules) IsLegalMove(p *Position, m Move) bool {
+	return containsMove(r.LegalMoves(p), m)
+}
+
+// このルールで合法手ならpでmを指す。
+func (r *Rules) Move(p *Position, m Move) error {
+	if !r.IsLegalMove(p, m) {
+		return fmt.Errorf("illegal move: %v, %v", p, m)
+	}
+	return p.move(m)
+}
+
+// 手番側の入玉宣言の結果。
+// 宣言が認められなければ宣言した側の反則負け。
+func (r *Rules) Declare(p *Position) Result {
+	c := p.Turn
+	lose := Result{Winner: c.Inv(), Reason: IllegalMoveKind}
+
+	if r.Impasse != Impasse24 && r.Impasse != Impasse27 {
+		return lose
+	}
+	king, ok := p.findKing(c)
+	if !ok || !inEnemyCamp(king, c) || p.IsInCheck() {
+		return lose
+	}
+	points, pieces := p.impassePoints(c)
+	if pieces < 10 {
+		return lose
+	}
+
+	if r.Impasse == Impasse24 {
+		switch {
+		case points >= 31:
+			return Result{Winner: c, Reason: KachiMoveKind}
+		case points >= 24:
+			return Result{Winner: NO_COLOR, Reason: JishogiMoveKind}
+		}
+		return lose
+	}
+	need := 28
+	if c == White {
+		need = 27
+	}
+	if points >= need {
+		return Result{Winner: c, Reason: KachiMoveKind}
+	}
+	return lose
+}
+
+func inEnemyCamp(s Square, c Color) bool {
+	if c == Black {
+		return s.rank <= 2
+	}
+	return s.rank >= 6
+}
+
+// 入玉宣言の点数と敵陣にある玉以外の駒の数。
+// 敵陣の駒と持駒を大駒5点、小駒1点で数える。
+func (p *Position) impassePoints(c Color) (int, int) {
+	point := func(pt PieceType) int {
+		switch pt.Demote() {
+		case KA, HI:
+			return 5
+		case OU:
+			return 0
+		}
+		return 1
+	}
+
+	points, pieces := 0, 0
+	for rank := 0; rank < 9; rank++ {
+		for file := 0; file < 9; file++ {
+			s := Square{file, rank}
+			piece := p.Get(s)
+			if piece == NO_PIECE || piece.Color() != c || piece.PieceType() == OU || !inEnemyCamp(s, c) {
+				continue
+			}
+			points += point(piece.PieceType())
+			pieces++
+		}
+	}
+	for pt := FU; pt <= HI; pt++ {
+		n, _ := p.HandGet(pt, c)
+		points += n * point(pt)
+	}
+	return points, pieces
+}
+
+// トライルールで玉を置けば勝ちになる升目。
+func trySquare(c Color) Square {
+	if c == Black {
+		return Square{4, 0}
+	}
+	return Square{4, 8}
+}
+
+// 指し手を指した直後のノードnが終局かどうかを判定する。legalMovesはnの局面の合法手。
+// 終局なら終局の指し手を返す。
+func (r *Rules) adjudicate(n *GameNode, legalMoves []Move) (MoveData, bool) {
+	p := n.Position
+	m := n.MoveData
+
+	if len(legalMoves) == 0 {
+		return NewMoveData(TsumiMove, p, NullSquare), true
+	}
+
+	if r.Impasse == TryRule && m.IsNormalMove() && m.Piece.PieceType() == OU && m.To == trySquare(m.Color) {
+		// 入玉勝ちのColorは勝った側
+		kachi := NewMoveData(KachiMove, p, NullSquare)
+		kachi.Color = m.Color
+		return kachi, true
+	}
+
+	if moveData, ok := r.repetition(n); ok {
+		return moveData, true
+	}
+
+	if r.MaxMoves > 0 && p.Ply >= r.MaxMoves {
+		return NewMoveData(MaxMovesMove, p, NullSquare), true
+	}
+
+	return MoveData{}, false
+}
+
+// 同一局面が4回現れたら千日手。
+// その間の一方の指し手が全て王手なら連続王手の千日手。
+func (r *Rules) repetition(n *GameNode) (MoveData, bool) {
+	key := n.positionKey()
+	count := 0
+	var first *GameNode
+	for prev := n; prev != nil; prev = prev.Prev {
+		if prev.positionKey() == key {
+			count++
+			first = prev
+		}
+	}
+	if count < 4 {
+		return MoveData{}, false
+	}
+
+	if r.PerpetualCheckLoses {
+		allChecks := map[Color]bool{Black: true, White: true}
+		for node := n; node != first; node = node.Prev {
+			if !node.Position.IsInCheck() {
+				allChecks[node.MoveData.Color] = false
+			}
+		}
+		for _, c := range []Color{Black, White} {
+			if allChecks[c] {
+				// 連続王手の千日手のColorは王手をかけられた側(勝った側)
+				moveData := NewMoveData(OuteSennichiteMove, n.Position, NullSquare)
+				moveData.Color = c.Inv()
+				return moveData, true
+			}
+		}
+	}
+	return NewMoveData(SennichiteMove, n.Position, NullSquare), true
+}
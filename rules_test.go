@@ -0,0 +1,161 @@
+package shogi
+
+import "testing"
+
+func playUSI(t *testing.T, tree *GameTree, usiMoves []string) {
+	t.Helper()
+	for _, usi := range usiMoves {
+		m, err := NewMoveFromUSI(usi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tree.Move(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRulesAdjudicate(t *testing.T) {
+	repeat := func(moves []string, n int) []string {
+		result := []string{}
+		for i := 0; i < n; i++ {
+			result = append(result, moves...)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		sfen     string
+		rules    Rules
+		usiMoves []string
+		want     Result
+	}{
+		{
+			name:     "千日手",
+			sfen:     "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1",
+			rules:    DefaultRules,
+			usiMoves: repeat([]string{"2h3h", "8b7b", "3h2h", "7b8b"}, 3),
+			want:     Result{Winner: NO_COLOR, Reason: SennichiteMoveKind},
+		},
+		{
+			name:     "連続王手の千日手",
+			sfen:     "8k/9/9/9/9/9/9/9/K6R1 b - 1",
+			rules:    DefaultRules,
+			usiMoves: repeat([]string{"2i1i", "1a2a", "1i2i", "2a1a"}, 3),
+			want:     Result{Winner: White, Reason: OuteSennichiteMoveKind},
+		},
+		{
+			name:     "連続王手の千日手を負けにしない",
+			sfen:     "8k/9/9/9/9/9/9/9/K6R1 b - 1",
+			rules:    Rules{},
+			usiMoves: repeat([]string{"2i1i", "1a2a", "1i2i", "2a1a"}, 3),
+			want:     Result{Winner: NO_COLOR, Reason: SennichiteMoveKind},
+		},
+		{
+			name:     "最大手数",
+			sfen:     "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1",
+			rules:    Rules{MaxMoves: 2},
+			usiMoves: []string{"7g7f", "3c3d"},
+			want:     Result{Winner: NO_COLOR, Reason: MaxMovesMoveKind},
+		},
+		{
+			name:     "トライルール",
+			sfen:     "9/4K4/9/9/9/9/9/9/4k4 b - 1",
+			rules:    Rules{Impasse: TryRule},
+			usiMoves: []string{"5b5a"},
+			want:     Result{Winner: Black, Reason: KachiMoveKind},
+		},
+		{
+			name:     "トライルールでなければ続く",
+			sfen:     "9/4K4/9/9/9/9/9/9/4k4 b - 1",
+			rules:    DefaultRules,
+			usiMoves: []string{"5b5a"},
+			want:     Result{Winner: NO_COLOR, Reason: NullMoveKind},
+		},
+		{
+			name:     "打ち歩詰めを許す",
+			sfen:     "7lk/7p1/9/7N1/9/9/9/9/K8 b P 1",
+			rules:    Rules{},
+			usiMoves: []string{"P*1b"},
+			want:     Result{Winner: Black, Reason: TsumiMoveKind},
+		},
+	}
+
+	for _, test := range tests {
+		tree, err := NewGameTreeFromSFEN(test.sfen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.Rules = test.rules
+		playUSI(t, tree, test.usiMoves)
+		if got := tree.Result(); got != test.want {
+			t.Errorf("%s: want %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestRulesPawnDropMate(t *testing.T) {
+	p, err := NewPositionFromSFEN("7lk/7p1/9/7N1/9/9/9/9/K8 b P 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMoveFromUSI("P*1b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if DefaultRules.IsLegalMove(p, m) {
+		t.Errorf("pawn drop mate should be illegal with DefaultRules")
+	}
+	if r := (Rules{}); !r.IsLegalMove(p, m) {
+		t.Errorf("pawn drop mate should be legal without ForbidPawnDropMate")
+	}
+
+	if err := p.Clone().Move(m); err == nil {
+		t.Errorf("want error for pawn drop mate with Position.Move")
+	}
+	if r := (Rules{}); r.Move(p.Clone(), m) != nil {
+		t.Errorf("pawn drop mate should be playable without ForbidPawnDropMate")
+	}
+
+	tree := NewGameTreeFromPosition(p)
+	if err := tree.Move(m); err == nil {
+		t.Errorf("want error for pawn drop mate")
+	}
+}
+
+func TestRulesDeclare(t *testing.T) {
+	tests := []struct {
+		sfen    string
+		impasse ImpasseRule
+		want    Result
+	}{
+		// 28点
+		{"4K4/RBGGSSNNL/PPPPPPPPP/9/9/9/9/9/4k4 b 2P 1", Impasse27, Result{Winner: Black, Reason: KachiMoveKind}},
+		{"4K4/RBGGSSNNL/PPPPPPPPP/9/9/9/9/9/4k4 b P 1", Impasse27, Result{Winner: White, Reason: IllegalMoveKind}},
+		{"4K4/RBGGSSNNL/PPPPPPPPP/9/9/9/9/9/4k4 b 2P 1", Impasse24, Result{Winner: NO_COLOR, Reason: JishogiMoveKind}},
+		{"4K4/RBGGSSNNL/PPPPPPPPP/9/9/9/9/9/4k4 b 2P 1", NoImpasse, Result{Winner: White, Reason: IllegalMoveKind}},
+		// 玉が敵陣にいない
+		{"9/RBGGSSNNL/PPPPPPPPP/4K4/9/9/9/9/4k4 b 2P 1", Impasse27, Result{Winner: White, Reason: IllegalMoveKind}},
+	}
+	for _, test := range tests {
+		p, err := NewPositionFromSFEN(test.sfen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := Rules{Impasse: test.impasse}
+		if got := r.Declare(p); got != test.want {
+			t.Errorf("%v %v: want %v, got %v", test.sfen, test.impasse, test.want, got)
+		}
+
+		// 宣言の結果が棋譜に反映される
+		tree := NewGameTreeFromPosition(p)
+		tree.Rules = r
+		if err := tree.Move(KachiMove); err != nil {
+			t.Fatal(err)
+		}
+		if got := tree.Result(); got != test.want {
+			t.Errorf("%v %v: tree want %v, got %v", test.sfen, test.impasse, test.want, got)
+		}
+	}
+}
@@ -99,3 +99,24 @@ func (s Square) KIF() string {
 	}
 	return fileKifMap[s.file] + rankKifMap[s.rank]
 }
+
+// "77"のような形式。"00"はNullSquare(駒台)。
+func NewSquareFromCSA(csa string) (Square, error) {
+	if len(csa) != 2 {
+		return NullSquare, fmt.Errorf("length should be 2: %v", csa)
+	}
+	if csa == "00" {
+		return NullSquare, nil
+	}
+	if csa[0] < '1' || csa[0] > '9' || csa[1] < '1' || csa[1] > '9' {
+		return NullSquare, fmt.Errorf("invalid csa: %v", csa)
+	}
+	return NewSquare(9-int(csa[0]-'0'), int(csa[1]-'1'))
+}
+
+func (s Square) CSA() string {
+	if s.IsNull() {
+		return "00"
+	}
+	return fmt.Sprintf("%d%d", 9-s.file, s.rank+1)
+}
@@ -0,0 +1,124 @@
+package tournament
+
+import (
+	"math"
+)
+
+// 勝ち、負け、引き分けの数。
+type Score struct {
+	Wins   int
+	Losses int
+	Draws  int
+}
+
+func (s Score) Games() int {
+	return s.Wins + s.Losses + s.Draws
+}
+
+// 引き分けを0.5とした勝率。
+func (s Score) Ratio() float64 {
+	n := s.Games()
+	if n == 0 {
+		return 0
+	}
+	return (float64(s.Wins) + float64(s.Draws)/2) / float64(n)
+}
+
+// 95%信頼区間の両側の幅の半分に使う正規分布の分位点
+const z95 = 1.959963984540054
+
+// 勝率0と1ではレーティング差が無限大になるので、勝率をこの値だけ内側に丸める。
+// 丸めたレーティング差は±1200程度になる。
+const eloEpsilon = 1e-3
+
+// 勝率からのレーティング差。
+func eloDiff(ratio float64) float64 {
+	ratio = math.Max(eloEpsilon, math.Min(1-eloEpsilon, ratio))
+	return -400 * math.Log10(1/ratio-1)
+}
+
+// レーティング差と95%信頼区間の幅(±margin)。
+// 全勝や全敗、局数が少なく信頼区間が勝率0か1を越える場合は、そこを丸めた有限の値になる。
+func (s Score) Elo() (elo, margin float64) {
+	n := float64(s.Games())
+	if n == 0 {
+		return 0, 0
+	}
+	w := float64(s.Wins) / n
+	l := float64(s.Losses) / n
+	d := float64(s.Draws) / n
+	mu := w + d/2
+
+	dev := w*math.Pow(1-mu, 2) + l*math.Pow(0-mu, 2) + d*math.Pow(0.5-mu, 2)
+	stdev := math.Sqrt(dev) / math.Sqrt(n)
+
+	elo = eloDiff(mu)
+	low := eloDiff(mu - z95*stdev)
+	high := eloDiff(mu + z95*stdev)
+	return elo, (high - low) / 2
+}
+
+type SPRTStatus uint8
+
+const (
+	// まだ判定できない
+	Continue SPRTStatus = iota
+	// H0(差はelo0以下)を採択
+	AcceptH0
+	// H1(差はelo1以上)を採択
+	AcceptH1
+)
+
+func (s SPRTStatus) String() string {
+	switch s {
+	case AcceptH0:
+		return "H0"
+	case AcceptH1:
+		return "H1"
+	}
+	return "continue"
+}
+
+// 逐次確率比検定(SPRT)の設定。
+type SPRT struct {
+	Elo0  float64
+	Elo1  float64
+	Alpha float64
+	Beta  float64
+}
+
+// 対数尤度比。勝ち負け引き分けの三項分布を正規分布で近似する。
+func (t SPRT) LLR(s Score) float64 {
+	n := float64(s.Games())
+	if n == 0 {
+		return 0
+	}
+	w := float64(s.Wins) / n
+	d := float64(s.Draws) / n
+	mu := w + d/2
+	// 1局あたりの分散
+	variance := w + d/4 - mu*mu
+	if variance <= 0 {
+		return 0
+	}
+	s0 := 1 / (1 + math.Pow(10, -t.Elo0/400))
+	s1 := 1 / (1 + math.Pow(10, -t.Elo1/400))
+	return n * (s1 - s0) * (2*mu - s0 - s1) / (2 * variance)
+}
+
+// 対数尤度比の下限と上限。
+func (t SPRT) Bounds() (lower, upper float64) {
+	return math.Log(t.Beta / (1 - t.Alpha)), math.Log((1 - t.Beta) / t.Alpha)
+}
+
+func (t SPRT) Status(s Score) SPRTStatus {
+	llr := t.LLR(s)
+	lower, upper := t.Bounds()
+	switch {
+	case llr >= upper:
+		return AcceptH1
+	case llr <= lower:
+		return AcceptH0
+	}
+	return Continue
+}
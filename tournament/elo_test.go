@@ -0,0 +1,68 @@
+package tournament
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScoreElo(t *testing.T) {
+	tests := []struct {
+		score  Score
+		elo    float64
+		margin float64
+	}{
+		{Score{Wins: 60, Losses: 40}, 70.44, 70.57},
+		{Score{Wins: 30, Losses: 30, Draws: 40}, 0, 53.16},
+		{Score{Wins: 40, Losses: 60, Draws: 20}, -58.45, 57.96},
+	}
+	for _, test := range tests {
+		elo, margin := test.score.Elo()
+		if math.Abs(elo-test.elo) > 0.01 || math.Abs(margin-test.margin) > 0.01 {
+			t.Errorf("%+v: want %.2f +/- %.2f, got %.2f +/- %.2f", test.score, test.elo, test.margin, elo, margin)
+		}
+	}
+}
+
+func TestScoreEloLopsided(t *testing.T) {
+	tests := []struct {
+		score  Score
+		elo    float64
+		margin float64
+	}{
+		{Score{Wins: 10}, 1199.83, 0},
+		{Score{Losses: 3}, -1199.83, 0},
+		{Score{Wins: 9, Losses: 1}, 381.70, 520.42},
+		{Score{Wins: 1}, 1199.83, 0},
+	}
+	for _, test := range tests {
+		elo, margin := test.score.Elo()
+		if math.IsNaN(elo) || math.IsInf(elo, 0) || math.IsNaN(margin) || math.IsInf(margin, 0) {
+			t.Errorf("%+v: got %v +/- %v", test.score, elo, margin)
+			continue
+		}
+		if math.Abs(elo-test.elo) > 0.01 || math.Abs(margin-test.margin) > 0.01 {
+			t.Errorf("%+v: want %.2f +/- %.2f, got %.2f +/- %.2f", test.score, test.elo, test.margin, elo, margin)
+		}
+	}
+}
+
+func TestSPRT(t *testing.T) {
+	sprt := SPRT{Elo0: 0, Elo1: 10, Alpha: 0.05, Beta: 0.05}
+	tests := []struct {
+		score  Score
+		llr    float64
+		status SPRTStatus
+	}{
+		{Score{Wins: 600, Losses: 400}, 5.56, AcceptH1},
+		{Score{Wins: 500, Losses: 500}, -0.41, Continue},
+		{Score{Wins: 400, Losses: 600}, -6.43, AcceptH0},
+	}
+	for _, test := range tests {
+		if llr := sprt.LLR(test.score); math.Abs(llr-test.llr) > 0.01 {
+			t.Errorf("%+v: want llr %.2f, got %.2f", test.score, test.llr, llr)
+		}
+		if status := sprt.Status(test.score); status != test.status {
+			t.Errorf("%+v: want %v, got %v", test.score, test.status, status)
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package tournament
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eru1a/shogi-go"
+	"github.com/eru1a/shogi-go/engine"
+	"github.com/eru1a/shogi-go/match"
+)
+
+type Mode uint8
+
+const (
+	// 全員が総当たりで対局する
+	RoundRobin Mode = iota
+	// Players[0]が他の全員と対局する
+	Gauntlet
+)
+
+// 棋譜の保存形式。
+type Format uint8
+
+const (
+	CSA Format = iota
+	KIF
+)
+
+type Player struct {
+	Name string
+	// エンジンの起動の設定
+	Config engine.EngineConfig
+	// nil以外ならConfigの代わりにこれでエンジンを作る
+	NewEngine func() (*engine.Engine, error)
+	// setoptionで送るオプション
+	Options map[string]string
+}
+
+func (p *Player) newEngine() (*engine.Engine, error) {
+	if p.NewEngine != nil {
+		return p.NewEngine()
+	}
+	return engine.NewEngineFromConfig(p.Config)
+}
+
+type Tournament struct {
+	Players []Player
+	Mode    Mode
+	// 各組み合わせの対局数。先後は1局ごとに入れ替え、同じ開始局面を2局ずつ使う。
+	GamesPerPair int
+	// 同時に指す対局数
+	Concurrency int
+	// 開始局面。空なら平手。
+	Openings    []*shogi.Position
+	TimeControl shogi.TimeControl
+	Grace       time.Duration
+	Rules       shogi.Rules
+	// 評価値による終局の判定。nilなら判定しない。
+	Adjudication *match.Adjudication
+	// 2人の対局でPlayers[0]から見た成績がSPRTで判定できたら打ち切る。nilなら打ち切らない。
+	SPRT *SPRT
+	// 棋譜と集計表を保存するディレクトリ。空なら保存しない。
+	OutputDir string
+	Format    Format
+}
+
+func NewTournament(players []Player) *Tournament {
+	return &Tournament{
+		Players:      players,
+		Mode:         RoundRobin,
+		GamesPerPair: 2,
+		Concurrency:  1,
+		Grace:        time.Second,
+		Rules:        shogi.DefaultRules,
+	}
+}
+
+// 1局の結果。BlackとWhiteはPlayersの添字。
+type GameResult struct {
+	Number int
+	Black  int
+	White  int
+	Tree   *shogi.GameTree
+	// エンジンが起動できなかったり対局中に終了したら、その側の負けになる。
+	Result shogi.Result
+	// 対局できなかったり途中でエラーになったらnil以外。
+	// Resultに勝者がなければ集計には含めない。
+	Err error
+	// 棋譜の保存のエラー。対局の結果には影響しない。
+	SaveErr error
+}
+
+type Summary struct {
+	Players []Player
+	Games   []GameResult
+	// Scores[i][j]はiのjに対する成績
+	Scores [][]Score
+	SPRT   *SPRT
+	LLR    float64
+	Status SPRTStatus
+}
+
+type game struct {
+	number       int
+	black, white int
+	position     *shogi.Position
+}
+
+// 対局の組み合わせ。
+func (t *Tournament) pairs() [][2]int {
+	pairs := [][2]int{}
+	for i := range t.Players {
+		for j := i + 1; j < len(t.Players); j++ {
+			if t.Mode == Gauntlet && i != 0 {
+				break
+			}
+			pairs = append(pairs, [2]int{i, j})
+		}
+	}
+	return pairs
+}
+
+// 全ての対局。組み合わせが偏らないように1局ずつ順番に並べる。
+func (t *Tournament) games() []game {
+	games := []game{}
+	for g := 0; g < t.GamesPerPair; g++ {
+		for _, pair := range t.pairs() {
+			position := shogi.NewPosition()
+			if len(t.Openings) > 0 {
+				position = t.Openings[g/2%len(t.Openings)]
+			}
+			black, white := pair[0], pair[1]
+			if g%2 == 1 {
+				black, white = white, black
+			}
+			games = append(games, game{len(games) + 1, black, white, position})
+		}
+	}
+	return games
+}
+
+// 全ての対局を指して集計する。
+// SPRTで判定できたら残りの対局は始めずに終わる。
+func (t *Tournament) Run(ctx context.Context) (*Summary, error) {
+	if len(t.Players) < 2 {
+		return nil, fmt.Errorf("tournament: need at least 2 players")
+	}
+	if t.OutputDir != "" {
+		if err := os.MkdirAll(t.OutputDir, 0755); err != nil {
+			return nil, fmt.Errorf("tournament: %v", err)
+		}
+	}
+	summary := &Summary{
+		Players: t.Players,
+		Scores:  make([][]Score, len(t.Players)),
+		SPRT:    t.SPRT,
+	}
+	for i := range summary.Scores {
+		summary.Scores[i] = make([]Score, len(t.Players))
+	}
+
+	concurrency := t.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	jobs := make(chan game)
+	results := make(chan GameResult)
+	stop := make(chan struct{})
+
+	go func() {
+		defer close(jobs)
+		for _, g := range t.games() {
+			select {
+			case jobs <- g:
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range jobs {
+				results <- t.play(ctx, g)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	stopped := false
+	for r := range results {
+		summary.add(r)
+		if t.SPRT != nil && len(t.Players) == 2 && !stopped {
+			score := summary.Scores[0][1]
+			summary.LLR = t.SPRT.LLR(score)
+			summary.Status = t.SPRT.Status(score)
+			if summary.Status != Continue {
+				stopped = true
+				close(stop)
+			}
+		}
+	}
+	sort.Slice(summary.Games, func(i, j int) bool {
+		return summary.Games[i].Number < summary.Games[j].Number
+	})
+
+	if t.OutputDir != "" {
+		path := filepath.Join(t.OutputDir, "summary.txt")
+		if err := ioutil.WriteFile(path, []byte(summary.Table()), 0644); err != nil {
+			return summary, fmt.Errorf("tournament: %v", err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return summary, fmt.Errorf("tournament: %v", err)
+	}
+	return summary, nil
+}
+
+// エンジンがquitで終了するのを待つ時間
+const closeTimeout = 5 * time.Second
+
+func closeEngine(e *engine.Engine) {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	e.Close(ctx)
+}
+
+// 1局指して棋譜を保存する。
+func (t *Tournament) play(ctx context.Context, g game) GameResult {
+	r := GameResult{Number: g.number, Black: g.black, White: g.white}
+	black, err := t.Players[g.black].newEngine()
+	if err != nil {
+		r.Err = err
+		r.Result = forfeit(shogi.Black)
+		return r
+	}
+	defer closeEngine(black)
+	white, err := t.Players[g.white].newEngine()
+	if err != nil {
+		r.Err = err
+		r.Result = forfeit(shogi.White)
+		return r
+	}
+	defer closeEngine(white)
+
+	m := match.NewMatch(black, white)
+	m.TimeControl = t.TimeControl
+	m.Grace = t.Grace
+	m.Rules = t.Rules
+	m.Adjudication = t.Adjudication
+	m.Position = g.position
+	m.BlackOptions = t.Players[g.black].Options
+	m.WhiteOptions = t.Players[g.white].Options
+
+	r.Tree, r.Err = m.Run(ctx)
+	if r.Err != nil && ctx.Err() == nil {
+		// 片方だけ終了していればその側の負け
+		_, blackExited := black.Exited()
+		_, whiteExited := white.Exited()
+		switch {
+		case blackExited && !whiteExited:
+			r.Result = forfeit(shogi.Black)
+		case whiteExited && !blackExited:
+			r.Result = forfeit(shogi.White)
+		}
+	}
+	if r.Tree == nil {
+		return r
+	}
+	if name := t.Players[g.black].Name; name != "" {
+		r.Tree.Info.Black = name
+	}
+	if name := t.Players[g.white].Name; name != "" {
+		r.Tree.Info.White = name
+	}
+	if !r.Result.IsOver() {
+		r.Result = r.Tree.Result()
+	}
+
+	if t.OutputDir != "" {
+		r.SaveErr = t.save(g, r.Tree)
+	}
+	return r
+}
+
+// エンジンの異常によるcの負け。
+func forfeit(c shogi.Color) shogi.Result {
+	return shogi.Result{Winner: c.Inv(), Reason: shogi.ChudanMoveKind}
+}
+
+func (t *Tournament) save(g game, tree *shogi.GameTree) error {
+	name := fmt.Sprintf("%04d_%s_%s", g.number, fileName(tree.Info.Black), fileName(tree.Info.White))
+	var data string
+	switch t.Format {
+	case KIF:
+		name += ".kif"
+		data = tree.KIF()
+	default:
+		name += ".csa"
+		data = tree.CSA()
+	}
+	return ioutil.WriteFile(filepath.Join(t.OutputDir, name), []byte(data), 0644)
+}
+
+// ファイル名に使えない文字を置き換える。
+func fileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func (s *Summary) add(r GameResult) {
+	s.Games = append(s.Games, r)
+	if !r.Result.IsOver() || r.Err != nil && r.Result.Winner == shogi.NO_COLOR {
+		return
+	}
+	b, w := r.Black, r.White
+	switch r.Result.Winner {
+	case shogi.Black:
+		s.Scores[b][w].Wins++
+		s.Scores[w][b].Losses++
+	case shogi.White:
+		s.Scores[w][b].Wins++
+		s.Scores[b][w].Losses++
+	default:
+		s.Scores[b][w].Draws++
+		s.Scores[w][b].Draws++
+	}
+}
+
+// iの全ての相手に対する成績。
+func (s *Summary) Total(i int) Score {
+	total := Score{}
+	for _, score := range s.Scores[i] {
+		total.Wins += score.Wins
+		total.Losses += score.Losses
+		total.Draws += score.Draws
+	}
+	return total
+}
+
+// 成績の表。相手全体に対するレーティング差の順に並べる。
+func (s *Summary) Table() string {
+	type row struct {
+		name        string
+		score       Score
+		elo, margin float64
+	}
+	rows := []row{}
+	for i, p := range s.Players {
+		total := s.Total(i)
+		elo, margin := total.Elo()
+		rows = append(rows, row{p.Name, total, elo, margin})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].score.Ratio() > rows[j].score.Ratio()
+	})
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Rank\tName\tElo\t+/-\tGames\tW\tL\tD\tScore\t")
+	for i, r := range rows {
+		fmt.Fprintf(w, "%d\t%s\t%.1f\t%.1f\t%d\t%d\t%d\t%d\t%.1f%%\t\n",
+			i+1, r.name, r.elo, r.margin, r.score.Games(), r.score.Wins, r.score.Losses, r.score.Draws, r.score.Ratio()*100)
+	}
+	w.Flush()
+
+	if s.SPRT != nil {
+		lower, upper := s.SPRT.Bounds()
+		fmt.Fprintf(&b, "SPRT: elo0 %.1f elo1 %.1f alpha %.2f beta %.2f llr %.2f (%.2f, %.2f) %v\n",
+			s.SPRT.Elo0, s.SPRT.Elo1, s.SPRT.Alpha, s.SPRT.Beta, s.LLR, lower, upper, s.Status)
+	}
+	return b.String()
+}
+
+// 開始局面のファイルを読む。1行に1局面で、SFENかUSIのpositionコマンドの形式。
+// 指し手が続く場合は指し終えた局面を使う。空行と#で始まる行は無視する。
+func LoadOpenings(path string) ([]*shogi.Position, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	openings := []*shogi.Position{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := parseOpening(line)
+		if err != nil {
+			return nil, fmt.Errorf("LoadOpenings: %v", err)
+		}
+		openings = append(openings, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return openings, nil
+}
+
+func parseOpening(line string) (*shogi.Position, error) {
+	switch strings.Fields(line)[0] {
+	case "position", "startpos", "sfen":
+		tree, err := shogi.NewGameTreeFromUSI(line)
+		if err != nil {
+			return nil, err
+		}
+		return tree.Current.Position, nil
+	}
+	return shogi.NewPositionFromSFEN(line)
+}
@@ -0,0 +1,189 @@
+package tournament
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eru1a/shogi-go"
+	"github.com/eru1a/shogi-go/engine"
+	"github.com/eru1a/shogi-go/engine/enginetest"
+)
+
+// modeが"resign"なら投了し、"flip"なら先手の時だけ投了し、"crash"ならgoで終了し、
+// それ以外は最初の合法手を指す。
+func fakePlayer(mode string) Player {
+	onGo := func(position, command string) []enginetest.Line {
+		tree, _ := shogi.NewGameTreeFromUSI(position)
+		switch {
+		case mode == "crash":
+			return []enginetest.Line{{Exit: true}}
+		case mode == "resign" || mode == "flip" && tree.Current.Position.Turn == shogi.Black:
+			return []enginetest.Line{{Text: "bestmove resign"}}
+		}
+		return []enginetest.Line{{Text: "bestmove " + tree.Current.Position.LegalMoves()[0].USI()}}
+	}
+	return Player{
+		Name: mode,
+		NewEngine: func() (*engine.Engine, error) {
+			f := &enginetest.Fake{Name: mode, OnGo: onGo}
+			return f.Engine(), nil
+		},
+	}
+}
+
+func fakePlayers(modes ...string) []Player {
+	players := []Player{}
+	for _, mode := range modes {
+		players = append(players, fakePlayer(mode))
+	}
+	return players
+}
+
+func TestTournamentRoundRobin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tournament")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tour := NewTournament(fakePlayers("first", "resign", "second"))
+	tour.GamesPerPair = 2
+	tour.Concurrency = 2
+	tour.TimeControl = shogi.TimeControl{Main: time.Minute}
+	tour.Rules.MaxMoves = 6
+	tour.OutputDir = filepath.Join(dir, "out")
+	tour.Format = KIF
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	summary, err := tour.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Games) != 6 {
+		t.Fatalf("want 6 games, got %d", len(summary.Games))
+	}
+	for _, g := range summary.Games {
+		if g.Err != nil || g.SaveErr != nil {
+			t.Fatal(g.Err, g.SaveErr)
+		}
+	}
+	if want := (Score{Draws: 2}); summary.Scores[0][2] != want {
+		t.Errorf("first vs second: want %+v, got %+v", want, summary.Scores[0][2])
+	}
+	if want := (Score{Losses: 4}); summary.Total(1) != want {
+		t.Errorf("resign: want %+v, got %+v", want, summary.Total(1))
+	}
+
+	files, err := filepath.Glob(filepath.Join(tour.OutputDir, "*.kif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 6 {
+		t.Errorf("want 6 kif files, got %v", files)
+	}
+	table, err := ioutil.ReadFile(filepath.Join(tour.OutputDir, "summary.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(table), "resign") {
+		t.Errorf("summary:\n%s", table)
+	}
+}
+
+func TestTournamentSPRT(t *testing.T) {
+	tour := NewTournament(fakePlayers("first", "flip"))
+	tour.Mode = Gauntlet
+	tour.GamesPerPair = 1000
+	tour.Rules.MaxMoves = 4
+	tour.TimeControl = shogi.TimeControl{Main: time.Minute}
+	tour.SPRT = &SPRT{Elo0: 0, Elo1: 10, Alpha: 0.05, Beta: 0.05}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	summary, err := tour.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Games) >= 1000 || summary.Status != AcceptH1 {
+		t.Errorf("want early stop, got %d games, %v", len(summary.Games), summary.Status)
+	}
+}
+
+func TestTournamentCrash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tournament")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	players := fakePlayers("first", "crash")
+	players = append(players, Player{Name: "missing", Config: engine.EngineConfig{Path: filepath.Join(dir, "missing")}})
+	tour := NewTournament(players)
+	tour.Mode = Gauntlet
+	tour.TimeControl = shogi.TimeControl{Main: time.Minute}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	summary, err := tour.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range summary.Games {
+		if g.Err == nil {
+			t.Errorf("game %d: want error", g.Number)
+		}
+	}
+	if want := (Score{Wins: 2}); summary.Scores[0][1] != want {
+		t.Errorf("first vs crash: want %+v, got %+v", want, summary.Scores[0][1])
+	}
+	if want := (Score{Wins: 2}); summary.Scores[0][2] != want {
+		t.Errorf("first vs missing: want %+v, got %+v", want, summary.Scores[0][2])
+	}
+}
+
+func TestTournamentSaveError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tournament")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tour := NewTournament(fakePlayers("first", "resign"))
+	tour.GamesPerPair = 1
+	tour.TimeControl = shogi.TimeControl{Main: time.Minute}
+	tour.OutputDir = filepath.Join(dir, "out")
+	// 棋譜と同じ名前のディレクトリがあると保存できない
+	if err := os.MkdirAll(filepath.Join(tour.OutputDir, "0001_first_resign.csa"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	summary, err := tour.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := summary.Games[0]; g.Err != nil || g.SaveErr == nil {
+		t.Errorf("want only save error, got %v, %v", g.Err, g.SaveErr)
+	}
+	if want := (Score{Wins: 1}); summary.Scores[0][1] != want {
+		t.Errorf("want %+v, got %+v", want, summary.Scores[0][1])
+	}
+}
+
+func TestTournamentPairs(t *testing.T) {
+	tour := NewTournament(make([]Player, 4))
+	if got := len(tour.pairs()); got != 6 {
+		t.Errorf("round robin: want 6 pairs, got %d", got)
+	}
+	tour.Mode = Gauntlet
+	if got := len(tour.pairs()); got != 3 {
+		t.Errorf("gauntlet: want 3 pairs, got %d", got)
+	}
+}